@@ -0,0 +1,218 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// DefaultConcurrency is the number of calendars fetched at once without --concurrency
+const DefaultConcurrency = 4
+
+// agendaOptions are the validated flags that choose which events are loaded
+type agendaOptions struct {
+	loc             *time.Location
+	filter          calendarFilter
+	concurrency     int
+	expandRecurring bool
+	profiles        []profile
+	// nonInteractive fails instead of asking for authorization even without --non-interactive
+	nonInteractive bool
+	// query chooses the events to load once the calendar's timezone is known
+	query func(loc *time.Location) (eventQuery, error)
+}
+
+// agenda is the set of events loaded for a range of days
+type agenda struct {
+	window    dateRange
+	profiles  []profile
+	calendars []*calendar.CalendarListEntry
+	events    []*Event
+}
+
+// parseAgendaFlags validates the flags used to load events so mistakes are reported before authorizing
+func parseAgendaFlags(c *cli.Context) (agendaOptions, error) {
+	options, err := parseCalendarFlags(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	// The range is rebuilt once the calendar's timezone is known
+	_, err = parseDateRange(c, time.Local)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	options.query = func(loc *time.Location) (eventQuery, error) {
+		window, err := parseDateRange(c, loc)
+		return eventQuery{window: window}, err
+	}
+
+	return options, nil
+}
+
+// parseCalendarFlags validates the flags that choose the accounts and calendars events are loaded from
+func parseCalendarFlags(c *cli.Context) (agendaOptions, error) {
+	err := checkFlags(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	loc, err := timezoneOverride(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	filter, err := newCalendarFilter(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	concurrency, err := getConcurrency(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	profiles, err := resolveProfiles(c)
+	if err != nil {
+		return agendaOptions{}, err
+	}
+
+	return agendaOptions{loc: loc, filter: filter, concurrency: concurrency, expandRecurring: true, profiles: profiles}, nil
+}
+
+// loadAgenda authorizes each profile in turn and then merges the events from their selected calendars.
+// The range uses the timezone of the first profile's calendar.
+func loadAgenda(c *cli.Context, cmdBuilder runner.Builder, options agendaOptions) (*agenda, error) {
+	result := &agenda{profiles: options.profiles, calendars: []*calendar.CalendarListEntry{}, events: []*Event{}}
+	var query eventQuery
+	for index, selected := range options.profiles {
+		srv, err := getCalendarService(c, cmdBuilder, selected.tokenFile, options.nonInteractive)
+		if err != nil {
+			return nil, err
+		}
+
+		calendars, err := listCalendars(srv)
+		if err != nil {
+			return nil, err
+		}
+
+		if index == 0 {
+			query, err = agendaQuery(srv, calendars, options)
+			if err != nil {
+				return nil, err
+			}
+
+			result.window = query.window
+		}
+
+		filter := options.filter
+		if selected.filter != nil {
+			filter = *selected.filter
+		}
+
+		calendars = filter.apply(calendars)
+		events, err := collectEvents(srv, calendars, query, options.concurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			event.Profile = selected.name
+		}
+
+		result.calendars = append(result.calendars, calendars...)
+		result.events = append(result.events, events...)
+	}
+
+	if len(options.profiles) > 1 {
+		sortEvents(result.events)
+		result.events = query.truncate(dedupeEvents(result.events))
+	}
+
+	return result, nil
+}
+
+// agendaQuery builds the query in options.loc, or in the timezone of the user's calendar when that is nil
+func agendaQuery(srv *calendar.Service, calendars []*calendar.CalendarListEntry, options agendaOptions) (eventQuery, error) {
+	loc := options.loc
+	if loc == nil {
+		var err error
+		loc, err = getLocation(srv, calendars)
+		if err != nil {
+			return eventQuery{}, err
+		}
+	}
+
+	query, err := options.query(loc)
+	query.expandRecurring = options.expandRecurring
+	return query, err
+}
+
+func listCalendars(srv *calendar.Service) ([]*calendar.CalendarListEntry, error) {
+	request := srv.CalendarList.List()
+	resp, err := request.Do()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to check calendar. %v", err)
+	}
+
+	calendars := resp.Items
+	for resp.NextPageToken != "" {
+		request.PageToken(resp.NextPageToken)
+		resp, err = request.Do()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to check calendar. %v", err)
+		}
+
+		calendars = append(calendars, resp.Items...)
+	}
+
+	return calendars, nil
+}
+
+func getConcurrency(c *cli.Context) (int, error) {
+	if !c.IsSet("concurrency") {
+		return DefaultConcurrency, nil
+	}
+
+	if c.Int("concurrency") < 1 {
+		return 0, cli.NewExitError("--concurrency must be at least 1", 1)
+	}
+
+	return c.Int("concurrency"), nil
+}
+
+// globalString looks up a flag that may be given either before or after a subcommand
+func globalString(c *cli.Context, name string) string {
+	if value := c.String(name); value != "" {
+		return value
+	}
+
+	return c.GlobalString(name)
+}
+
+// globalBool looks up a boolean flag that may be given either before or after a subcommand
+func globalBool(c *cli.Context, name string) bool {
+	return c.Bool(name) || c.GlobalBool(name)
+}
+
+// globalInt looks up an integer flag that may be given either before or after a subcommand
+func globalInt(c *cli.Context, name string) int {
+	if value := c.Int(name); value != 0 {
+		return value
+	}
+
+	return c.GlobalInt(name)
+}
+
+// globalDuration looks up a duration flag that may be given either before or after a subcommand
+func globalDuration(c *cli.Context, name string) time.Duration {
+	if value := c.Duration(name); value != 0 {
+		return value
+	}
+
+	return c.GlobalDuration(name)
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdAuthLogin authorizes calChecker to read the user's calendars and saves the token
+func CmdAuthLogin(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker auth login\"", 1)
+		}
+
+		tokenClient, err := authCommandClient(c, cmdBuilder)
+		if err != nil {
+			return err
+		}
+
+		err = tokenClient.Login(c.App.Writer, c.Bool("device"))
+		if err != nil {
+			return tokenError(err)
+		}
+
+		fmt.Fprintf(c.App.Writer, "Authorized, the token was saved to %s\n", tokenClient.tokenCacheFile)
+		return nil
+	}
+}
+
+// CmdAuthStatus shows the saved token or the credentials calChecker uses
+func CmdAuthStatus(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker auth status\"", 1)
+		}
+
+		tokenClient, err := authCommandClient(c, cmdBuilder)
+		if err != nil {
+			return err
+		}
+
+		return tokenClient.Status(c.App.Writer)
+	}
+}
+
+// CmdAuthLogout revokes the saved token and deletes the token file
+func CmdAuthLogout(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker auth logout\"", 1)
+		}
+
+		tokenClient, err := authCommandClient(c, cmdBuilder)
+		if err != nil {
+			return err
+		}
+
+		return tokenClient.Logout(c.App.Writer)
+	}
+}
+
+func authCommandClient(c *cli.Context, cmdBuilder runner.Builder) (*Client, error) {
+	err := checkFlags(c)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := resolveProfile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokenClient(c, cmdBuilder, selected.tokenFile)
+}
+
+// ExitAuthorizationRequired is the exit code used when --non-interactive is given and the user must authorize
+const ExitAuthorizationRequired = 3
+
+// ExitInterrupted is the exit code used when the user interrupts the authorization
+const ExitInterrupted = 130
+
+// authOptions control how calChecker asks the user for authorization
+type authOptions struct {
+	noBrowser      bool
+	nonInteractive bool
+	timeout        time.Duration
+	impersonate    string
+	browserCommand string
+	redirectHost   string
+	redirectPort   int
+}
+
+// newTokenClient builds a Client that keeps its token in tokenFile from the credential and authorization flags
+func newTokenClient(c *cli.Context, cmdBuilder runner.Builder, tokenFile string) (*Client, error) {
+	var tokenClient *Client
+	if globalBool(c, "adc") {
+		tokenClient = NewDefaultClient(cmdBuilder)
+	} else {
+		var err error
+		tokenClient, err = NewClient(globalString(c, "credentialFile"), tokenFile, cmdBuilder)
+		if err != nil {
+			return nil, fmt.Errorf("Could not initialize token client: %v", err)
+		}
+	}
+
+	if tokenClient.config != nil {
+		// Unlike a directory the user chose, the default one is created the first time it is needed
+		if filepath.Dir(tokenFile) == filepath.Dir(DefaultTokenFile()) {
+			err := os.MkdirAll(filepath.Dir(tokenFile), 0700)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to create the token directory: %v", err)
+			}
+		}
+
+		tokenClient.SetTokenStore(newTokenStore(c, tokenFile))
+	}
+
+	tokenClient.options = authOptions{
+		noBrowser:      globalBool(c, "no-browser"),
+		nonInteractive: globalBool(c, "non-interactive"),
+		timeout:        globalDuration(c, "auth-timeout"),
+		impersonate:    globalString(c, "impersonate"),
+		browserCommand: globalString(c, "browser-command"),
+		redirectHost:   globalString(c, "redirect-host"),
+		redirectPort:   globalInt(c, "redirect-port"),
+	}
+
+	if tokenClient.options.redirectPort < 0 || tokenClient.options.redirectPort > 65535 {
+		return nil, cli.NewExitError("Invalid redirect-port, it must be between 0 and 65535", 1)
+	}
+
+	if tokenClient.options.impersonate != "" && tokenClient.config != nil {
+		return nil, cli.NewExitError("--impersonate requires service account credentials", 1)
+	}
+
+	return tokenClient, nil
+}
+
+// newTokenStore encrypts the token file when --encrypt-token is given or the file is already encrypted
+func newTokenStore(c *cli.Context, tokenFile string) TokenStore {
+	var warnings io.Writer = os.Stderr
+	if c.App.ErrWriter != nil {
+		warnings = c.App.ErrWriter
+	}
+
+	if globalBool(c, "encrypt-token") || isEncryptedTokenFile(tokenFile) {
+		return NewEncryptedTokenStore(tokenFile, warnings, passphraseFromEnvOrTerminal(warnings))
+	}
+
+	return NewFileTokenStore(tokenFile, warnings)
+}
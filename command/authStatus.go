@@ -0,0 +1,164 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+	"golang.org/x/oauth2"
+)
+
+// TokenInfoURL is Google's token information endpoint, it allows overriding the endpoint for testing
+var TokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// RevokeURL is Google's token revocation endpoint, it allows overriding the endpoint for testing
+var RevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// Status describes the credentials calChecker would use
+func (client Client) Status(writer io.Writer) error {
+	tabW := tabwriter.NewWriter(writer, 0, 0, 1, ' ', 0)
+	if client.config == nil {
+		fmt.Fprintf(tabW, "Credentials:\t%s\n", client.describeCredentials())
+		if client.options.impersonate != "" {
+			fmt.Fprintf(tabW, "Impersonating:\t%s\n", client.options.impersonate)
+		}
+
+		return tabW.Flush()
+	}
+
+	fmt.Fprintf(tabW, "Token file:\t%s\n", client.tokenCacheFile)
+	token, err := client.store.Load()
+	if err != nil {
+		_ = tabW.Flush()
+		return cli.NewExitError(fmt.Sprintf("Not logged in: %v", err), 1)
+	}
+
+	// Getting the scopes needs a valid access token, which refreshes and saves an expired token
+	current, err := client.tokenSource(token).Token()
+	var scopes string
+	if err == nil {
+		token = current
+		scopes, err = tokenScopes(token)
+	}
+
+	if err != nil {
+		scopes = fmt.Sprintf("unknown (%v)", err)
+	}
+
+	fmt.Fprintf(tabW, "Expires:\t%s\n", describeExpiry(token))
+	fmt.Fprintf(tabW, "Refresh token:\t%s\n", yesNo(token.RefreshToken != ""))
+	fmt.Fprintf(tabW, "Scopes:\t%s\n", scopes)
+	return tabW.Flush()
+}
+
+// Logout revokes the saved token with Google and deletes the token file
+func (client Client) Logout(writer io.Writer) error {
+	if client.config == nil {
+		return fmt.Errorf("Service accounts and application default credentials can not log out")
+	}
+
+	token, err := client.store.Load()
+	if os.IsNotExist(err) {
+		fmt.Fprintf(writer, "Not logged in, there is no token file at %s\n", client.tokenCacheFile)
+		return nil
+	}
+
+	if err == nil {
+		err = revokeToken(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	// An unreadable token file can not be revoked but is still removed
+	err = client.store.Delete()
+	if err != nil {
+		return fmt.Errorf("Unable to delete token file: %v", err)
+	}
+
+	fmt.Fprintf(writer, "Logged out, deleted %s\n", client.tokenCacheFile)
+	return nil
+}
+
+func (client Client) describeCredentials() string {
+	if client.jwtConfig != nil {
+		return "service account " + client.jwtConfig.Email
+	}
+
+	return "application default credentials"
+}
+
+// tokenScopes asks Google which scopes the access token was granted
+func tokenScopes(token *oauth2.Token) (string, error) {
+	response, err := http.PostForm(TokenInfoURL, url.Values{"access_token": {token.AccessToken}})
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token information request failed: %s", response.Status)
+	}
+
+	info := struct {
+		Scope string `json:"scope"`
+	}{}
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(strings.Fields(info.Scope), ", "), nil
+}
+
+// revokeToken revokes the refresh token, which also revokes its access tokens, or just the access token when there
+// is no refresh token.  A token Google no longer recognizes has nothing left to revoke.
+func revokeToken(token *oauth2.Token) error {
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+
+	response, err := http.PostForm(RevokeURL, url.Values{"token": {value}})
+	if err != nil {
+		return fmt.Errorf("Unable to revoke token: %v", err)
+	}
+
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("Unable to revoke token: %s", response.Status)
+	}
+
+	return nil
+}
+
+func describeExpiry(token *oauth2.Token) string {
+	if token.Expiry.IsZero() {
+		return "never"
+	}
+
+	remaining := token.Expiry.Sub(Now())
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (expired %s ago)", token.Expiry.Format(time.RFC3339), formatDuration(-remaining))
+	}
+
+	return fmt.Sprintf("%s (in %s)", token.Expiry.Format(time.RFC3339), formatDuration(remaining))
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
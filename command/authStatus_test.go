@@ -0,0 +1,215 @@
+package command_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+// mockAuthAPI is a stand in for Google's token, token information and revocation endpoints
+type mockAuthAPI struct {
+	*httptest.Server
+	mutex        sync.Mutex
+	revokeStatus int
+	revoked      []string
+}
+
+func getMockAuthAPI(t *testing.T) *mockAuthAPI {
+	api := &mockAuthAPI{revokeStatus: http.StatusOK}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/tokeninfo":
+			if r.FormValue("access_token") != "fakeToken" && r.FormValue("access_token") != "newToken" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			_, err := w.Write([]byte(`{"aud":"id","scope":"https://www.googleapis.com/auth/calendar.readonly openid","expires_in":"3599"}`))
+			assert.Nil(t, err)
+		case "/revoke":
+			api.mutex.Lock()
+			api.revoked = append(api.revoked, r.FormValue("token"))
+			api.mutex.Unlock()
+			w.WriteHeader(api.revokeStatus)
+		default:
+			assert.Equal(t, "refresh_token", r.FormValue("grant_type"))
+			_, err := w.Write([]byte(`{"access_token":"newToken","expires_in":3600,"token_type":"Bearer"}`))
+			assert.Nil(t, err)
+		}
+	}))
+	command.TokenInfoURL = api.URL + "/tokeninfo"
+	command.RevokeURL = api.URL + "/revoke"
+	return api
+}
+
+func (api *mockAuthAPI) Close() {
+	api.Server.Close()
+	command.TokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	command.RevokeURL = "https://oauth2.googleapis.com/revoke"
+}
+
+func TestCmdAuthStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{
+			"valid",
+			`{"access_token":"fakeToken","refresh_token":"refresh","expiry":"2999-01-01T01:30:00Z"}`,
+			"Token file:    /tmp/testCalChecker/tokenFile\n" +
+				"Expires:       2999-01-01T01:30:00Z (in 1h30m)\n" +
+				"Refresh token: yes\n" +
+				"Scopes:        https://www.googleapis.com/auth/calendar.readonly, openid\n",
+		},
+		{
+			"no expiry",
+			`{"access_token":"fakeToken"}`,
+			"Token file:    /tmp/testCalChecker/tokenFile\n" +
+				"Expires:       never\n" +
+				"Refresh token: no\n" +
+				"Scopes:        https://www.googleapis.com/auth/calendar.readonly, openid\n",
+		},
+		{
+			"revoked",
+			`{"access_token":"revokedToken"}`,
+			"Token file:    /tmp/testCalChecker/tokenFile\n" +
+				"Expires:       never\n" +
+				"Refresh token: no\n" +
+				"Scopes:        unknown (token information request failed: 400 Bad Request)\n",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2999-01-01T00:00:00Z")()
+			api := getMockAuthAPI(t)
+			defer api.Close()
+			app, writer, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(testFolder, "tokenFile"), []byte(testCase.token), 0600))
+			assert.Nil(t, command.CmdAuthStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected, writer.String())
+		})
+	}
+}
+
+func TestCmdAuthStatusRefreshesExpiredToken(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockAuthAPI(t)
+	defer api.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte(`{"access_token":"oldToken","refresh_token":"refresh","expiry":"2017-11-08T07:00:00Z"}`), 0600))
+	assert.Nil(t, command.CmdAuthStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Regexp(
+		t,
+		`^Token file:    /tmp/testCalChecker/tokenFile
+Expires:       \S+ \(in (59m|1h)\)
+Refresh token: yes
+Scopes:        https://www.googleapis.com/auth/calendar.readonly, openid
+$`,
+		writer.String(),
+	)
+	assert.Equal(t, "newToken", readTokenFile(t, tokenFile).AccessToken)
+}
+
+func TestCmdAuthStatusNotLoggedIn(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	assert.EqualError(
+		t,
+		command.CmdAuthStatus(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Not logged in: open /tmp/testCalChecker/tokenFile: no such file or directory",
+	)
+	assert.Equal(t, "Token file: /tmp/testCalChecker/tokenFile\n", writer.String())
+}
+
+func TestCmdAuthStatusServiceAccount(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, writer, set := getServiceAccountAppAndFlagSet(t, testFolder, "")
+	assert.Nil(t, set.Set("impersonate", "me@example.com"))
+	assert.Nil(t, command.CmdAuthStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Credentials:   service account calchecker@project.iam.gserviceaccount.com\nImpersonating: me@example.com\n", writer.String())
+}
+
+func TestCmdAuthLogout(t *testing.T) {
+	cases := []struct {
+		name     string
+		token    string
+		revoked  string
+		status   int
+		expected string
+	}{
+		{"refresh token", `{"access_token":"fakeToken","refresh_token":"refresh"}`, "refresh", http.StatusOK, ""},
+		{"access token", `{"access_token":"fakeToken"}`, "fakeToken", http.StatusOK, ""},
+		{"already revoked", `{"access_token":"fakeToken","refresh_token":"refresh"}`, "refresh", http.StatusBadRequest, ""},
+		{"revoke failure", `{"access_token":"fakeToken","refresh_token":"refresh"}`, "refresh", http.StatusInternalServerError, "Unable to revoke token: 500 Internal Server Error"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			api := getMockAuthAPI(t)
+			defer api.Close()
+			api.revokeStatus = testCase.status
+			app, writer, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+			tokenFile := filepath.Join(testFolder, "tokenFile")
+			assert.Nil(t, ioutil.WriteFile(tokenFile, []byte(testCase.token), 0600))
+			err := command.CmdAuthLogout(&runner.Test{})(cli.NewContext(app, set, nil))
+			assert.Equal(t, []string{testCase.revoked}, api.revoked)
+			_, statErr := os.Stat(tokenFile)
+			if testCase.expected != "" {
+				assert.EqualError(t, err, testCase.expected)
+				assert.Nil(t, statErr)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.True(t, os.IsNotExist(statErr))
+			assert.Equal(t, "Logged out, deleted /tmp/testCalChecker/tokenFile\n", writer.String())
+		})
+	}
+}
+
+func TestCmdAuthLogoutNotLoggedIn(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockAuthAPI(t)
+	defer api.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+	assert.Nil(t, command.CmdAuthLogout(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Not logged in, there is no token file at /tmp/testCalChecker/tokenFile\n", writer.String())
+	assert.Equal(t, []string(nil), api.revoked)
+}
+
+func TestCmdAuthUsage(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	assert.Nil(t, set.Parse([]string{"foo"}))
+	assert.EqualError(t, command.CmdAuthStatus(&runner.Test{})(cli.NewContext(app, set, nil)), "Usage: \"calChecker auth status\"")
+	assert.EqualError(t, command.CmdAuthLogout(&runner.Test{})(cli.NewContext(app, set, nil)), "Usage: \"calChecker auth logout\"")
+}
@@ -0,0 +1,80 @@
+package command_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestCmdAuthLogin(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(testFolder, "tokenFile"), []byte(`{"access_token":"oldToken"}`), 0600))
+	set.Bool("device", false, "doc")
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	var OAuthURL string
+	ec.Closure = func(command string) {
+		OAuthURL = strings.Replace(command, "xdg-open ", "", -1)
+		_, err := http.Get(OAuthURL)
+		assert.Nil(t, err)
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Equal(
+		t,
+		fmt.Sprintf("Attempting to open %s in your browser\nAuthorized, the token was saved to /tmp/testCalChecker/tokenFile\n", OAuthURL),
+		writer.String(),
+	)
+	assert.Equal(t, "fakeToken", readTokenFile(t, filepath.Join(testFolder, "tokenFile")).AccessToken)
+}
+
+func TestCmdAuthLoginUsage(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	assert.Nil(t, set.Parse([]string{"foo"}))
+	assert.EqualError(t, command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)), "Usage: \"calChecker auth login\"")
+}
+
+func TestCmdAuthLoginNoTokenFile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	assert.Nil(t, set.Set("tokenFile", ""))
+	assert.EqualError(t, command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)), "You must specify a tokenFile")
+}
+
+func TestCmdCheckNonInteractive(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	set.Bool("non-interactive", true, "doc")
+	cb := &runner.Test{}
+	err := command.CmdCheck(cb)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Could not get OAuth token: Authorization is required, run calChecker auth login")
+	exitErr, ok := err.(cli.ExitCoder)
+	require.True(t, ok)
+	assert.Equal(t, command.ExitAuthorizationRequired, exitErr.ExitCode())
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Equal(t, "", writer.String())
+}
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/urfave/cli"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// calendarFilter chooses which calendars events are fetched from
+type calendarFilter struct {
+	include []calendarMatcher
+	exclude []calendarMatcher
+}
+
+// calendarMatcher matches a calendar by its exact ID or by a regular expression on its summary
+type calendarMatcher struct {
+	id      string
+	summary *regexp.Regexp
+}
+
+// newCalendarFilter builds a filter from the --calendar and --exclude-calendar flags
+func newCalendarFilter(c *cli.Context) (calendarFilter, error) {
+	include, err := newCalendarMatchers(c.StringSlice("calendar"), "calendar")
+	if err != nil {
+		return calendarFilter{}, err
+	}
+
+	exclude, err := newCalendarMatchers(c.StringSlice("exclude-calendar"), "exclude-calendar")
+	if err != nil {
+		return calendarFilter{}, err
+	}
+
+	return calendarFilter{include: include, exclude: exclude}, nil
+}
+
+func newCalendarMatchers(patterns []string, flagName string) ([]calendarMatcher, error) {
+	matchers := make([]calendarMatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		summary, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, cli.NewExitError(fmt.Sprintf("Invalid --%s pattern %q: %v", flagName, pattern, err), 1)
+		}
+
+		matchers = append(matchers, calendarMatcher{id: pattern, summary: summary})
+	}
+
+	return matchers, nil
+}
+
+func (matcher calendarMatcher) matches(item *calendar.CalendarListEntry) bool {
+	return item.Id == matcher.id || matcher.summary.MatchString(item.Summary) || (item.SummaryOverride != "" && matcher.summary.MatchString(item.SummaryOverride))
+}
+
+// apply returns the calendars that pass the filter.
+// Without any --calendar flags every calendar the user has selected in Google Calendar is included.
+func (filter calendarFilter) apply(calendars []*calendar.CalendarListEntry) []*calendar.CalendarListEntry {
+	selected := []*calendar.CalendarListEntry{}
+	for _, item := range calendars {
+		if filter.included(item) && !anyMatch(filter.exclude, item) {
+			selected = append(selected, item)
+		}
+	}
+
+	return selected
+}
+
+func (filter calendarFilter) included(item *calendar.CalendarListEntry) bool {
+	if len(filter.include) == 0 {
+		return item.Selected
+	}
+
+	return anyMatch(filter.include, item)
+}
+
+func anyMatch(matchers []calendarMatcher, item *calendar.CalendarListEntry) bool {
+	for _, matcher := range matchers {
+		if matcher.matches(item) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// calendarLabel is the name shown for a calendar in the agenda
+func calendarLabel(item *calendar.CalendarListEntry) string {
+	if item.SummaryOverride != "" {
+		return item.SummaryOverride
+	}
+
+	if item.Summary != "" {
+		return item.Summary
+	}
+
+	return item.Id
+}
@@ -0,0 +1,116 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckCalendars(t *testing.T) {
+	cases := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected string
+	}{
+		{
+			"selected",
+			nil,
+			nil,
+			"All Day      Team  Offsite\nWed, 9:00AM  Me    Standup\nWed, 1:00PM  Team  Planning\n",
+		},
+		{
+			"by id",
+			[]string{"holidays@example.com"},
+			nil,
+			"All Day  Founders Day\n",
+		},
+		{
+			"by summary regex",
+			[]string{"^T", "Hol"},
+			nil,
+			"All Day      Team      Offsite\nAll Day      Holidays  Founders Day\nWed, 1:00PM  Team      Planning\n",
+		},
+		{
+			"exclude",
+			nil,
+			[]string{"team@example.com"},
+			"Wed, 9:00AM  Standup\n",
+		},
+		{
+			"include and exclude",
+			[]string{"."},
+			[]string{"(?i)holidays"},
+			"All Day      Team  Offsite\nWed, 9:00AM  Me    Standup\nWed, 1:00PM  Team  Planning\n",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T08:00:00Z")()
+			api := getMockCalendarAPI(t, getTestCalendars(), getTestCalendarEvents())
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			for _, value := range testCase.include {
+				assert.Nil(t, set.Set("calendar", value))
+			}
+
+			for _, value := range testCase.exclude {
+				assert.Nil(t, set.Set("exclude-calendar", value))
+			}
+
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected, writer.String())
+		})
+	}
+}
+
+func TestCmdCheckInvalidCalendarPattern(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("exclude-calendar", "team("))
+	cb := &runner.Test{}
+	assert.EqualError(
+		t,
+		command.CmdCheck(cb)(cli.NewContext(app, set, nil)),
+		"Invalid --exclude-calendar pattern \"team(\": error parsing regexp: missing closing ): `team(`",
+	)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
+
+func getTestCalendars() []*calendar.CalendarListEntry {
+	return []*calendar.CalendarListEntry{
+		{Id: "me@example.com", Summary: "me@example.com", SummaryOverride: "Me", Primary: true, Selected: true, TimeZone: "UTC"},
+		{Id: "team@example.com", Summary: "Team", Selected: true},
+		{Id: "holidays@example.com", Summary: "Holidays"},
+	}
+}
+
+func getTestCalendarEvents() map[string][]*calendar.Event {
+	return map[string][]*calendar.Event{
+		"me@example.com": {
+			{Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, Summary: "Standup"},
+		},
+		"team@example.com": {
+			{Start: &calendar.EventDateTime{DateTime: "2017-11-08T13:00:00Z"}, Summary: "Planning"},
+			{Start: &calendar.EventDateTime{Date: "2017-11-08"}, Summary: "Offsite"},
+		},
+		"holidays@example.com": {
+			{Start: &calendar.EventDateTime{Date: "2017-11-08"}, Summary: "Founders Day"},
+		},
+	}
+}
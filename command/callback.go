@@ -0,0 +1,108 @@
+package command
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const callbackPath = "/oauth2callback"
+
+const callbackPage = `<!DOCTYPE html>
+<html><head><title>calChecker</title></head><body><p>%s</p></body></html>
+`
+
+// callbackResult is the outcome of the authorization redirect
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler serves the OAuth redirect, accepting only callbacks that carry the expected state
+type callbackHandler struct {
+	state   string
+	results chan callbackResult
+}
+
+func newCallbackHandler(state string) *callbackHandler {
+	return &callbackHandler{state: state, results: make(chan callbackResult, 1)}
+}
+
+func (handler *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != callbackPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	// A callback without the state we sent did not come from our authorization request, so keep waiting
+	if r.FormValue("state") != handler.state {
+		writeCallbackPage(w, http.StatusBadRequest, "Invalid authorization state.  Please try again from calChecker.")
+		return
+	}
+
+	if reason := r.FormValue("error"); reason != "" {
+		writeCallbackPage(w, http.StatusForbidden, "Authorization failed: "+reason)
+		handler.finish(callbackResult{err: fmt.Errorf("Authorization failed: %s", reason)})
+		return
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		writeCallbackPage(w, http.StatusBadRequest, "Authorization failed: no code was received")
+		handler.finish(callbackResult{err: fmt.Errorf("Authorization failed: no code was received")})
+		return
+	}
+
+	writeCallbackPage(w, http.StatusOK, "calChecker is now authorized.  You may close this window.")
+	handler.finish(callbackResult{code: code})
+}
+
+// wait returns the code from the callback.  It gives up after timeout, unless timeout is 0, or on an interrupt.
+func (handler *callbackHandler) wait(timeout time.Duration, interrupts <-chan os.Signal) (string, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case result := <-handler.results:
+		return result.code, result.err
+	case <-expired:
+		return "", fmt.Errorf("Timed out after %v waiting for authorization", timeout)
+	case <-interrupts:
+		return "", cli.NewExitError("Authorization was interrupted", ExitInterrupted)
+	}
+}
+
+// finish records the first result, ignoring any callbacks after it
+func (handler *callbackHandler) finish(result callbackResult) {
+	select {
+	case handler.results <- result:
+	default:
+	}
+}
+
+func writeCallbackPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, callbackPage, html.EscapeString(message))
+}
+
+// randomString returns an unguessable value for the OAuth state parameter or a PKCE verifier
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("Unable to generate random value: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
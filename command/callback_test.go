@@ -0,0 +1,292 @@
+package command_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestGetHTTPCLientRandomState(t *testing.T) {
+	states := []string{}
+	for index := 0; index < 2; index++ {
+		authURL := authorizeWithCallback(t, func(t *testing.T, redirect string, state string) {
+			assert.Equal(t, http.StatusOK, getStatus(t, fmt.Sprintf("%s?code=foo&state=%s", redirect, url.QueryEscape(state))))
+		}, nil)
+		parsed, err := url.Parse(authURL)
+		require.Nil(t, err)
+		states = append(states, parsed.Query().Get("state"))
+	}
+
+	assert.Len(t, states[0], 43)
+	assert.NotEqual(t, "state-token", states[0])
+	assert.NotEqual(t, states[0], states[1])
+}
+
+func TestGetHTTPCLientIgnoresInvalidCallbacks(t *testing.T) {
+	authorizeWithCallback(t, func(t *testing.T, redirect string, state string) {
+		parsed, err := url.Parse(redirect)
+		require.Nil(t, err)
+		parsed.Path = "/favicon.ico"
+		assert.Equal(t, http.StatusNotFound, getStatus(t, parsed.String()+"?code=favicon&state="+url.QueryEscape(state)))
+		assert.Equal(t, http.StatusBadRequest, getStatus(t, redirect+"?code=forged&state=wrong"))
+		assert.Equal(t, http.StatusBadRequest, getStatus(t, redirect+"?code=forged"))
+		assert.Equal(t, http.StatusOK, getStatus(t, fmt.Sprintf("%s?code=foo&state=%s", redirect, url.QueryEscape(state))))
+	}, nil)
+}
+
+func TestGetHTTPCLientAccessDenied(t *testing.T) {
+	authorizeWithCallback(t, func(t *testing.T, redirect string, state string) {
+		assert.Equal(t, http.StatusForbidden, getStatus(t, fmt.Sprintf("%s?error=access_denied&state=%s", redirect, url.QueryEscape(state))))
+	}, fmt.Errorf("Authorization failed: access_denied"))
+}
+
+func TestGetHTTPCLientMissingCode(t *testing.T) {
+	authorizeWithCallback(t, func(t *testing.T, redirect string, state string) {
+		assert.Equal(t, http.StatusBadRequest, getStatus(t, fmt.Sprintf("%s?state=%s", redirect, url.QueryEscape(state))))
+	}, fmt.Errorf("Authorization failed: no code was received"))
+}
+
+// authorizeWithCallback runs the browser flow, letting callback play the part of the browser after the user
+// responds to the consent screen.  The token endpoint only accepts the code "foo".
+func authorizeWithCallback(t *testing.T, callback func(t *testing.T, redirect string, state string), expectedErr error) string {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	defer removeFile(t, testFolder)
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	credentialFile := filepath.Join(testFolder, "credentials")
+	tokenCacheFile := filepath.Join(testFolder, "token")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "foo", r.FormValue("code"))
+		_, err := w.Write([]byte(url.Values{"access_token": []string{"fakeToken"}}.Encode()))
+		assert.Nil(t, err)
+	}))
+	defer ts.Close()
+	assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	var authURL string
+	done := make(chan struct{})
+	ec.Closure = func(command string) {
+		authURL = strings.Replace(command, "xdg-open ", "", -1)
+		parsed, err := url.Parse(authURL)
+		require.Nil(t, err)
+		go func() {
+			callback(t, parsed.Query().Get("redirect_uri"), parsed.Query().Get("state"))
+			close(done)
+		}()
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	client, err := command.NewClient(credentialFile, tokenCacheFile, cb)
+	require.Nil(t, err)
+	httpClient, err := client.GetHTTPClient(&bytes.Buffer{})
+	<-done
+	if expectedErr != nil {
+		assert.EqualError(t, err, expectedErr.Error())
+		assert.Nil(t, httpClient)
+		_, statErr := os.Stat(tokenCacheFile)
+		assert.True(t, os.IsNotExist(statErr))
+	} else {
+		assert.Nil(t, err)
+		assert.NotNil(t, httpClient)
+	}
+
+	assert.Equal(t, []error(nil), cb.Errors)
+	return authURL
+}
+
+func getStatus(t *testing.T, address string) int {
+	response, err := http.Get(address)
+	require.Nil(t, err)
+	assert.Nil(t, response.Body.Close())
+	return response.StatusCode
+}
+
+func TestGetHTTPCLientPKCEVerifierMismatch(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	defer removeFile(t, testFolder)
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	credentialFile := filepath.Join(testFolder, "credentials")
+	tokenCacheFile := filepath.Join(testFolder, "token")
+	checker := &pkceChecker{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checker.verified(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, err := w.Write([]byte(`{"error":"invalid_grant"}`))
+			assert.Nil(t, err)
+		}
+	}))
+	defer ts.Close()
+	assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	ec.Closure = func(command string) {
+		authURL, err := url.Parse(strings.Replace(command, "xdg-open ", "", -1))
+		require.Nil(t, err)
+		query := authURL.Query()
+		query.Set("code_challenge", strings.Repeat("A", 43))
+		checker.recordChallenge(t, &http.Request{Form: query})
+		go func() {
+			_, err := http.Get(fmt.Sprintf("%s?code=foo&state=%s", query.Get("redirect_uri"), url.QueryEscape(query.Get("state"))))
+			assert.Nil(t, err)
+		}()
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	client, err := command.NewClient(credentialFile, tokenCacheFile, cb)
+	require.Nil(t, err)
+	_, err = client.GetHTTPClient(&bytes.Buffer{})
+	assert.EqualError(t, err, "Unable to retrieve token from web: oauth2: cannot fetch token: 400 Bad Request\nResponse: {\"error\":\"invalid_grant\"}")
+}
+
+func TestCmdAuthLoginTimeout(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	login := abandonLogin(t, testFolder, "20ms", func() {})
+	err := command.CmdAuthLogin(login.cb)(cli.NewContext(login.app, login.set, nil))
+	assert.EqualError(t, err, "Could not get OAuth token: Timed out after 20ms waiting for authorization")
+	login.assertClosed(t)
+}
+
+func TestCmdAuthLoginInterrupted(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	login := abandonLogin(t, testFolder, "0s", func() {
+		assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	})
+	err := command.CmdAuthLogin(login.cb)(cli.NewContext(login.app, login.set, nil))
+	assert.EqualError(t, err, "Could not get OAuth token: Authorization was interrupted")
+	exitErr, ok := err.(cli.ExitCoder)
+	require.True(t, ok)
+	assert.Equal(t, command.ExitInterrupted, exitErr.ExitCode())
+	login.assertClosed(t)
+}
+
+// abandonedLogin is a browser login where the user never finishes authorizing
+type abandonedLogin struct {
+	app         *cli.App
+	set         *flag.FlagSet
+	cb          *runner.Test
+	redirectURI string
+}
+
+// abandonLogin prepares a login that calls openBrowser in place of opening the authorization URL
+func abandonLogin(t *testing.T, testFolder, timeout string, openBrowser func()) *abandonedLogin {
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "http://127.0.0.1:1")
+	set.Duration("auth-timeout", 0, "doc")
+	assert.Nil(t, set.Set("auth-timeout", timeout))
+	login := &abandonedLogin{app: app, set: set}
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	ec.Closure = func(command string) {
+		authURL, err := url.Parse(strings.Replace(command, "xdg-open ", "", -1))
+		require.Nil(t, err)
+		login.redirectURI = authURL.Query().Get("redirect_uri")
+		openBrowser()
+	}
+	login.cb = &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	return login
+}
+
+// assertClosed checks that the browser was opened and the callback server is no longer listening
+func (login *abandonedLogin) assertClosed(t *testing.T) {
+	assert.Equal(t, []*runner.ExpectedCommand{}, login.cb.ExpectedCommands)
+	assert.Equal(t, []error(nil), login.cb.Errors)
+	require.NotEqual(t, "", login.redirectURI)
+	_, err := http.Get(login.redirectURI)
+	assert.NotNil(t, err)
+}
+
+func TestCmdAuthLoginBrowserCommandAndRedirectPort(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	port := getFreePort(t)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	set.Bool("device", false, "doc")
+	set.String("browser-command", "firefox --new-window {url}", "doc")
+	set.String("redirect-host", "localhost", "doc")
+	set.Int("redirect-port", port, "doc")
+	ec := runner.NewExpectedCommand("", "firefox --new-window .*", "", 0)
+	var authURL string
+	ec.Closure = func(command string) {
+		authURL = strings.Replace(command, "firefox --new-window ", "", -1)
+		assert.Equal(t, http.StatusOK, getStatus(t, authURL))
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
+	assert.Equal(t, []error(nil), cb.Errors)
+	parsed, err := url.Parse(authURL)
+	require.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("http://localhost:%d/oauth2callback", port), parsed.Query().Get("redirect_uri"))
+	assert.Equal(t, "fakeToken", readTokenFile(t, filepath.Join(testFolder, "tokenFile")).AccessToken)
+}
+
+func TestCmdAuthLoginBrowserCommandWithoutURL(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	set.Bool("device", false, "doc")
+	set.String("browser-command", "open -a Safari", "doc")
+	ec := runner.NewExpectedCommand("", "open -a Safari http://.*", "", 0)
+	ec.Closure = func(command string) {
+		assert.Equal(t, http.StatusOK, getStatus(t, strings.Replace(command, "open -a Safari ", "", -1)))
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
+
+func TestCmdAuthLoginRedirectPortInUse(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer func() {
+		assert.Nil(t, listener.Close())
+	}()
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	set.Bool("device", false, "doc")
+	set.Int("redirect-port", listener.Addr().(*net.TCPAddr).Port, "doc")
+	err = command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil))
+	require.NotNil(t, err)
+	assert.Regexp(t, "^Could not get OAuth token: Unable to listen for the authorization redirect: listen tcp 127.0.0.1:[0-9]+: ", err.Error())
+}
+
+func TestCmdAuthLoginInvalidRedirectPort(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	set.Bool("device", false, "doc")
+	set.Int("redirect-port", 70000, "doc")
+	assert.EqualError(t, command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)), "Invalid redirect-port, it must be between 0 and 65535")
+}
+
+func getFreePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	assert.Nil(t, listener.Close())
+	return port
+}
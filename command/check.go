@@ -2,9 +2,6 @@ package command
 
 import (
 	"fmt"
-	"io"
-	"text/tabwriter"
-	"time"
 
 	"github.com/guywithnose/runner"
 	"github.com/urfave/cli"
@@ -21,53 +18,40 @@ func CmdCheck(cmdBuilder runner.Builder) func(c *cli.Context) error {
 			return cli.NewExitError("Usage: \"calChecker\"", 1)
 		}
 
-		err := checkFlags(c)
+		options, err := parseAgendaFlags(c)
 		if err != nil {
 			return err
 		}
 
-		srv, err := getCalendarService(c.String("credentialFile"), c.String("tokenFile"), c.App.Writer, cmdBuilder)
+		output, err := parseOutputFlags(c)
 		if err != nil {
 			return err
 		}
 
-		request := srv.CalendarList.List()
-		resp, err := request.Do()
-		if err != nil {
-			return fmt.Errorf("Unable to check calendar. %v", err)
-		}
-
-		err = parseCalendars(srv, resp.Items, c.App.Writer)
+		agenda, err := loadAgenda(c, cmdBuilder, options)
 		if err != nil {
 			return err
 		}
 
-		for resp.NextPageToken != "" {
-			request.PageToken(resp.NextPageToken)
-			resp, err = request.Do()
-			if err != nil {
-				return fmt.Errorf("Unable to check calendar. %v", err)
-			}
-
-			err = parseCalendars(srv, resp.Items, c.App.Writer)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
+		return newRenderer(output, agenda.window, len(agenda.calendars) > 1, len(agenda.profiles) > 1).render(c.App.Writer, agenda.events)
 	}
 }
 
-func getCalendarService(credentialFile, tokenFile string, w io.Writer, cmdBuilder runner.Builder) (*calendar.Service, error) {
-	tokenClient, err := NewClient(credentialFile, tokenFile, cmdBuilder)
+// getCalendarService authorizes with the token in tokenFile.  nonInteractive fails when the user must authorize,
+// the same as --non-interactive.
+func getCalendarService(c *cli.Context, cmdBuilder runner.Builder, tokenFile string, nonInteractive bool) (*calendar.Service, error) {
+	tokenClient, err := newTokenClient(c, cmdBuilder, tokenFile)
 	if err != nil {
-		return nil, fmt.Errorf("Could not initialize token client: %v", err)
+		return nil, err
+	}
+
+	if nonInteractive {
+		tokenClient.options.nonInteractive = true
 	}
 
-	httpClient, err := tokenClient.GetHTTPClient(w)
+	httpClient, err := tokenClient.GetHTTPClient(c.App.Writer)
 	if err != nil {
-		return nil, fmt.Errorf("Could not get OAuth token: %v", err)
+		return nil, tokenError(err)
 	}
 
 	srv, _ := calendar.New(httpClient)
@@ -78,65 +62,27 @@ func getCalendarService(credentialFile, tokenFile string, w io.Writer, cmdBuilde
 	return srv, nil
 }
 
-func parseCalendars(srv *calendar.Service, items []*calendar.CalendarListEntry, w io.Writer) error {
-	for _, item := range items {
-		if item.Primary {
-			midnight := fmt.Sprintf("%sT00:00:00Z", time.Now().Format("2006-01-02"))
-			tomorrow := fmt.Sprintf("%sT00:00:00Z", time.Now().Add(time.Hour*24).Format("2006-01-02"))
-			request := srv.Events.List(item.Id).TimeMin(midnight).TimeMax(tomorrow).SingleEvents(true)
-			resp, err := request.Do()
-			if err != nil {
-				return fmt.Errorf("Unable to check calendar. %v", err)
-			}
-
-			err = parseEvents(resp.Items, w)
-			if err != nil {
-				return err
-			}
-
-			for resp.NextPageToken != "" {
-				request.PageToken(resp.NextPageToken)
-				resp, err = request.Do()
-				if err != nil {
-					return err
-				}
-
-				err = parseEvents(resp.Items, w)
-				if err != nil {
-					return err
-				}
-			}
-		}
+// tokenError explains a failure to get a token, keeping the exit code of errors that have one
+func tokenError(err error) error {
+	message := fmt.Sprintf("Could not get OAuth token: %v", err)
+	if exitErr, ok := err.(cli.ExitCoder); ok {
+		return cli.NewExitError(message, exitErr.ExitCode())
 	}
 
-	return nil
+	return fmt.Errorf("%s", message)
 }
 
-func parseEvents(items []*calendar.Event, w io.Writer) error {
-	tabW := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
-	defer tabW.Flush()
-	for _, event := range items {
-		if event.Start.DateTime != "" {
-			start, err := time.Parse(time.RFC3339, event.Start.DateTime)
-			if err != nil {
-				return err
-			}
-
-			fmt.Fprintf(tabW, "%s\t%s\n", start.Format("Mon, 3:04PM"), event.Summary)
-		} else {
-			fmt.Fprintf(tabW, "All Day\t%s\n", event.Summary)
-		}
+func checkFlags(c *cli.Context) error {
+	if globalBool(c, "adc") {
+		return nil
 	}
 
-	return nil
-}
-
-func checkFlags(c *cli.Context) error {
-	if c.String("credentialFile") == "" {
+	if globalString(c, "credentialFile") == "" {
 		return cli.NewExitError("You must specify a credentialFile", 1)
 	}
 
-	if c.String("tokenFile") == "" {
+	// Service accounts get a fresh token every run so they have nothing to store
+	if globalString(c, "tokenFile") == "" && !isServiceAccountFile(globalString(c, "credentialFile")) {
 		return cli.NewExitError("You must specify a tokenFile", 1)
 	}
 
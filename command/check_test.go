@@ -45,11 +45,11 @@ func TestCmdCheck(t *testing.T) {
 	assert.Nil(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)))
 	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
 	assert.Equal(t, []error(nil), cb.Errors)
-	dayOfWeek := time.Now().Format("Mon")
+	dayOfWeek := time.Now().UTC().Format("Mon")
 	assert.Equal(
 		t,
 		fmt.Sprintf(
-			"Attempting to open %s in your browser\n%s, 12:00PM  Something is going to happen\n%s, 1:00PM  Another thing is going to happen\nAll Day      All day event\n",
+			"Attempting to open %s in your browser\nAll Day       All day event\n%s, 12:00PM  Something is going to happen\n%s, 1:00PM   Another thing is going to happen\n",
 			OAuthURL,
 			dayOfWeek,
 			dayOfWeek,
@@ -190,13 +190,15 @@ func getTestCredentials(fakeAPI string) []byte {
 }
 
 func getMockGoogleAPI(t *testing.T) *httptest.Server {
+	checker := &pkceChecker{}
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		b, err := ioutil.ReadAll(r.Body)
 		assert.Nil(t, err)
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
 		if strings.Contains(r.URL.String(), "access_type=offline") {
+			checker.recordChallenge(t, r)
 			go func() {
-				_, err = http.Get(fmt.Sprintf("%s?code=foo", r.FormValue("redirect_uri")))
+				_, err = http.Get(fmt.Sprintf("%s?code=foo&state=%s", r.FormValue("redirect_uri"), url.QueryEscape(r.FormValue("state"))))
 				assert.Nil(t, err)
 			}()
 			return
@@ -222,8 +224,10 @@ func getMockGoogleAPI(t *testing.T) *httptest.Server {
 			resp := calendar.CalendarList{
 				Items: []*calendar.CalendarListEntry{
 					{
-						Primary: true,
-						Id:      "primary",
+						Primary:  true,
+						Selected: true,
+						Id:       "primary",
+						TimeZone: "UTC",
 					},
 				},
 			}
@@ -235,15 +239,15 @@ func getMockGoogleAPI(t *testing.T) *httptest.Server {
 
 		timeParameters := fmt.Sprintf(
 			"timeMax=%sT00%%3A00%%3A00Z&timeMin=%sT00%%3A00%%3A00Z",
-			time.Now().Add(time.Hour*24).Format("2006-01-02"),
-			time.Now().Format("2006-01-02"),
+			time.Now().UTC().Add(time.Hour*24).Format("2006-01-02"),
+			time.Now().UTC().Format("2006-01-02"),
 		)
 		if r.URL.String() == fmt.Sprintf("/calendars/primary/events?alt=json&singleEvents=true&%s", timeParameters) {
 			resp := calendar.Events{
 				Items: []*calendar.Event{
 					{
 						Start: &calendar.EventDateTime{
-							DateTime: fmt.Sprintf("%sT12:00:00Z", time.Now().Format("2006-01-02")),
+							DateTime: fmt.Sprintf("%sT12:00:00Z", time.Now().UTC().Format("2006-01-02")),
 						},
 						Summary: "Something is going to happen",
 					},
@@ -261,12 +265,13 @@ func getMockGoogleAPI(t *testing.T) *httptest.Server {
 				Items: []*calendar.Event{
 					{
 						Start: &calendar.EventDateTime{
-							DateTime: fmt.Sprintf("%sT13:00:00Z", time.Now().Format("2006-01-02")),
+							DateTime: fmt.Sprintf("%sT13:00:00Z", time.Now().UTC().Format("2006-01-02")),
 						},
 						Summary: "Another thing is going to happen",
 					},
 					{
-						Start:   &calendar.EventDateTime{},
+						Start:   &calendar.EventDateTime{Date: time.Now().UTC().Format("2006-01-02")},
+						End:     &calendar.EventDateTime{Date: time.Now().UTC().Add(time.Hour * 24).Format("2006-01-02")},
 						Summary: "All day event",
 					},
 				},
@@ -278,6 +283,7 @@ func getMockGoogleAPI(t *testing.T) *httptest.Server {
 		}
 
 		require.Equal(t, "foo", r.FormValue("code"))
+		require.True(t, checker.verified(r))
 		response := url.Values{"access_token": []string{"fakeToken"}}
 		_, err = w.Write([]byte(response.Encode()))
 		assert.Nil(t, err)
@@ -291,7 +297,7 @@ func getMockGoogleAPICalendarFailure(t *testing.T) *httptest.Server {
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
 		if strings.Contains(r.URL.String(), "access_type=offline") {
 			go func() {
-				_, err = http.Get(fmt.Sprintf("%s?code=foo", r.FormValue("redirect_uri")))
+				_, err = http.Get(fmt.Sprintf("%s?code=foo&state=%s", r.FormValue("redirect_uri"), url.QueryEscape(r.FormValue("state"))))
 				assert.Nil(t, err)
 			}()
 			return
@@ -316,7 +322,7 @@ func getMockGoogleAPITokenFailure(t *testing.T) *httptest.Server {
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
 		if strings.Contains(r.URL.String(), "access_type=offline") {
 			go func() {
-				_, err := http.Get(fmt.Sprintf("%s?code=foo", r.FormValue("redirect_uri")))
+				_, err := http.Get(fmt.Sprintf("%s?code=foo&state=%s", r.FormValue("redirect_uri"), url.QueryEscape(r.FormValue("state"))))
 				assert.Nil(t, err)
 			}()
 			return
@@ -325,3 +331,15 @@ func getMockGoogleAPITokenFailure(t *testing.T) *httptest.Server {
 		w.WriteHeader(500)
 	}))
 }
+
+func TestCmdCheckFlagsBeforeCommand(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	c := getAuthorizedCommandContext(t, testFolder, "check", "--days", "0")
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdCheck(cb)(c), "--days must be at least 1")
+	assert.Equal(t, []error(nil), cb.Errors)
+}
@@ -0,0 +1,355 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	configMetadataKey = "config"
+	profilesKey       = "profiles"
+)
+
+// config is the contents of the config file.  Its settings are named after the flags they provide values for.
+type config struct {
+	path     string
+	settings map[string]interface{}
+	profiles map[string]profileConfig
+	// applied records the flags that got their value from the config rather than the command line or environment
+	applied map[string]bool
+}
+
+// configConflicts lists the flags that can not be given together with each flag.  A setting is left out of a run
+// where a flag it conflicts with was given on the command line or in the environment.
+var configConflicts = map[string][]string{
+	"date":          {"from", "to"},
+	"from":          {"date"},
+	"to":            {"date", "days"},
+	"days":          {"to"},
+	"output":        {"format", "template-file"},
+	"format":        {"output", "template-file"},
+	"template-file": {"output", "format"},
+}
+
+// profileConfig holds the settings of one profile in the config file
+type profileConfig struct {
+	TokenFile       string   `yaml:"tokenFile"`
+	Calendar        []string `yaml:"calendar"`
+	ExcludeCalendar []string `yaml:"exclude-calendar"`
+}
+
+// ConfigFile is the config file used when --config is not given, $XDG_CONFIG_HOME/calChecker/config.yaml
+func ConfigFile() string {
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), Name, "config.yaml")
+}
+
+// DefaultTokenFile is the token file used when --tokenFile is not given, $XDG_DATA_HOME/calChecker/token.json
+func DefaultTokenFile() string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), Name, "token.json")
+}
+
+// xdgDir returns the directory in the environment variable name, or fallback in the home directory when it is not set
+func xdgDir(name, fallback string) string {
+	if dir := os.Getenv(name); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(os.Getenv("HOME"), fallback)
+}
+
+// ApplyConfig loads the config file and uses its settings for any flags of c that were not given on the command line
+// or in the environment.  It is meant to be the Before function of the app and of each command with its own flags.
+// A command's flags first take the values given before the command name.
+func ApplyConfig(c *cli.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+
+	flags := c.App.Flags
+	settings := cfg.settings
+	if c.Command.Name != "" {
+		err = applyFlagsBeforeCommand(c, cfg)
+		if err != nil {
+			return err
+		}
+
+		flags = c.Command.Flags
+		settings = cfg.commandSettings(c.Command, c.App.Flags)
+	}
+
+	applied := map[string]bool{}
+	given := func(name string) bool {
+		return !applied[name] && c.IsSet(name)
+	}
+
+	for _, flag := range flags {
+		name := flagName(flag)
+		value, ok := settings[name]
+		if !ok || given(name) || conflictGiven(name, given) {
+			continue
+		}
+
+		err = setFromConfig(c, name, value)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("Invalid %s in %s: %v", name, cfg.path, err), 1)
+		}
+
+		applied[name] = true
+		cfg.applied[name] = true
+	}
+
+	return nil
+}
+
+// conflictGiven reports whether a flag that can not be used with name was given
+func conflictGiven(name string, given func(string) bool) bool {
+	for _, conflict := range configConflicts[name] {
+		if given(conflict) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyFlagsBeforeCommand sets the command's flags that were given before the command name.  The app has the flags of
+// the commands for its default action, so a command can get them either before or after its name.  A flag the command
+// does not have, or has with another meaning, is rejected rather than ignored.
+func applyFlagsBeforeCommand(c *cli.Context, cfg *config) error {
+	for _, appFlag := range c.App.Flags {
+		name := flagName(appFlag)
+		if !c.GlobalIsSet(name) || cfg.applied[name] || !commandsHaveFlag(c.App, name) {
+			continue
+		}
+
+		commandFlag := lookupFlag(c.Command.Flags, name)
+		if commandFlag == nil {
+			return cli.NewExitError(fmt.Sprintf("--%s can not be used with %s", name, c.Command.Name), 1)
+		}
+
+		if !reflect.DeepEqual(commandFlag, appFlag) {
+			return cli.NewExitError(fmt.Sprintf("--%s means something else for %s, give it after the command name", name, c.Command.Name), 1)
+		}
+
+		if c.IsSet(name) {
+			continue
+		}
+
+		values := []string{c.GlobalString(name)}
+		if _, isSlice := appFlag.(cli.StringSliceFlag); isSlice {
+			values = c.GlobalStringSlice(name)
+		}
+
+		for _, value := range values {
+			err := c.Set(name, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// commandsHaveFlag reports whether any of the app's commands has the flag name
+func commandsHaveFlag(app *cli.App, name string) bool {
+	for _, command := range app.Commands {
+		if hasFlag(command.Flags, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commandSettings returns the settings for a command's flags.  A command flag that is the same as one of the app's
+// flags uses the top level setting, the command's own flags are set in a section named after the command.
+func (cfg *config) commandSettings(command cli.Command, appFlags []cli.Flag) map[string]interface{} {
+	settings := map[string]interface{}{}
+	for _, flag := range command.Flags {
+		for _, appFlag := range appFlags {
+			if value, ok := cfg.settings[flagName(flag)]; ok && reflect.DeepEqual(flag, appFlag) {
+				settings[flagName(flag)] = value
+			}
+		}
+	}
+
+	section, _ := cfg.settings[command.Name].(map[interface{}]interface{})
+	for name, value := range section {
+		settings[fmt.Sprint(name)] = value
+	}
+
+	return settings
+}
+
+func flagName(flag cli.Flag) string {
+	return strings.TrimSpace(strings.Split(flag.GetName(), ",")[0])
+}
+
+// getConfig returns the config loaded for this run, loading it the first time
+func getConfig(c *cli.Context) (*config, error) {
+	if c.App.Metadata == nil {
+		c.App.Metadata = map[string]interface{}{}
+	}
+
+	if cfg, ok := c.App.Metadata[configMetadataKey].(*config); ok {
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.App.Metadata[configMetadataKey] = cfg
+	return cfg, nil
+}
+
+// loadConfig reads --config or the default config file.  A missing default config file is the same as an empty one.
+func loadConfig(c *cli.Context) (*config, error) {
+	cfg := &config{path: globalString(c, "config"), settings: map[string]interface{}{}, applied: map[string]bool{}}
+	explicit := cfg.path != ""
+	if !explicit {
+		cfg.path = ConfigFile()
+	}
+
+	contents, err := ioutil.ReadFile(cfg.path)
+	if os.IsNotExist(err) && !explicit {
+		return cfg, nil
+	}
+
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Unable to read config file: %v", err), 1)
+	}
+
+	err = yaml.Unmarshal(contents, &cfg.settings)
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Unable to parse config file %s: %v", cfg.path, err), 1)
+	}
+
+	// Profiles are decoded again on their own so misspelled profile settings are reported
+	profiles, err := yaml.Marshal(cfg.settings[profilesKey])
+	if err == nil {
+		err = yaml.UnmarshalStrict(profiles, &cfg.profiles)
+	}
+
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Unable to parse the profiles in config file %s: %v", cfg.path, err), 1)
+	}
+
+	delete(cfg.settings, profilesKey)
+	err = cfg.validate(c.App)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// validate rejects settings that are not the name of a flag or a command with flags, and invalid profile names
+func (cfg *config) validate(app *cli.App) error {
+	for name, value := range cfg.settings {
+		if hasFlag(app.Flags, name) {
+			continue
+		}
+
+		command := app.Command(name)
+		section, isSection := value.(map[interface{}]interface{})
+		if command == nil || !isSection {
+			return cli.NewExitError(fmt.Sprintf("Unknown setting %q in %s", name, cfg.path), 1)
+		}
+
+		for key := range section {
+			if !hasFlag(command.Flags, fmt.Sprint(key)) {
+				return cli.NewExitError(fmt.Sprintf("Unknown setting %q for %s in %s", key, name, cfg.path), 1)
+			}
+		}
+	}
+
+	for name := range cfg.profiles {
+		if !profileNamePattern.MatchString(name) || name == allProfiles {
+			return cli.NewExitError(fmt.Sprintf("Invalid profile name %q in %s", name, cfg.path), 1)
+		}
+	}
+
+	return nil
+}
+
+func hasFlag(flags []cli.Flag, name string) bool {
+	return lookupFlag(flags, name) != nil
+}
+
+// lookupFlag returns the flag with the name or alias name, or nil when there is none
+func lookupFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, flag := range flags {
+		for _, flagAlias := range strings.Split(flag.GetName(), ",") {
+			if strings.TrimSpace(flagAlias) == name {
+				return flag
+			}
+		}
+	}
+
+	return nil
+}
+
+// setFromConfig sets the flag name to a config value, setting a list flag once for each item of a list
+func setFromConfig(c *cli.Context, name string, value interface{}) error {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	for _, item := range values {
+		switch item.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			return fmt.Errorf("expected a value or a list of values")
+		}
+
+		err := c.Set(name, expandHome(fmt.Sprint(item)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(value string) string {
+	if strings.HasPrefix(value, "~/") {
+		return filepath.Join(os.Getenv("HOME"), value[2:])
+	}
+
+	return value
+}
+
+// fromConfig reports whether the flag name got its value from the config
+func fromConfig(c *cli.Context, name string) bool {
+	cfg, ok := c.App.Metadata[configMetadataKey].(*config)
+	return ok && cfg.applied[name]
+}
+
+// configProfiles returns the profiles in the config, sorted by name
+func configProfiles(c *cli.Context) (map[string]profileConfig, []string) {
+	cfg, ok := c.App.Metadata[configMetadataKey].(*config)
+	if !ok {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(cfg.profiles))
+	for name := range cfg.profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return cfg.profiles, names
+}
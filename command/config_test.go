@@ -0,0 +1,361 @@
+package command_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestConfigDefaultPaths(t *testing.T) {
+	defer setEnv(t, "XDG_CONFIG_HOME", "/xdg/config")()
+	defer setEnv(t, "XDG_DATA_HOME", "/xdg/data")()
+	assert.Equal(t, "/xdg/config/calChecker/config.yaml", command.ConfigFile())
+	assert.Equal(t, "/xdg/data/calChecker/token.json", command.DefaultTokenFile())
+
+	assert.Nil(t, os.Unsetenv("XDG_CONFIG_HOME"))
+	assert.Nil(t, os.Unsetenv("XDG_DATA_HOME"))
+	defer setEnv(t, "HOME", "/home/me")()
+	assert.Equal(t, "/home/me/.config/calChecker/config.yaml", command.ConfigFile())
+	assert.Equal(t, "/home/me/.local/share/calChecker/token.json", command.DefaultTokenFile())
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, "HOME", "/home/me")()
+	configFile := writeConfig(t, testFolder, `
+credentialFile: ~/credentials.json
+timezone: America/Chicago
+output: json
+days: 3
+show-end: true
+calendar:
+  - work@example.com
+  - Team
+`)
+	defer setEnv(t, "CALCHECKER_TEST_TIMEZONE", "Europe/London")()
+	c := getConfigContext(t, "--config", configFile, "--output", "jsonl")
+	require.Nil(t, command.ApplyConfig(c))
+	assert.Equal(t, "/home/me/credentials.json", c.String("credentialFile"))
+	assert.Equal(t, "Europe/London", c.String("timezone"))
+	assert.Equal(t, "jsonl", c.String("output"))
+	assert.Equal(t, 3, c.Int("days"))
+	assert.True(t, c.Bool("show-end"))
+	assert.Equal(t, []string{"work@example.com", "Team"}, c.StringSlice("calendar"))
+	assert.Equal(t, 4, c.Int("concurrency"))
+}
+
+func TestApplyConfigCommandSection(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	configFile := writeConfig(t, testFolder, `
+format: "{{.Summary}}"
+calendar: [work@example.com]
+export:
+  file: week.ics
+`)
+	global := getConfigContext(t, "--config", configFile)
+	require.Nil(t, command.ApplyConfig(global))
+	c := getCommandContext(t, global, "export")
+	require.Nil(t, command.ApplyConfig(c))
+	assert.Equal(t, "week.ics", c.String("file"))
+	assert.Equal(t, []string{"work@example.com"}, c.StringSlice("calendar"))
+	// The template format does not apply to the export format flag with the same name
+	assert.Equal(t, "ics", c.String("format"))
+}
+
+func TestApplyConfigFlagsBeforeCommand(t *testing.T) {
+	defer setEnv(t, "XDG_CONFIG_HOME", filepath.Join(os.TempDir(), "testCalChecker"))()
+	global := getConfigContext(t, "--days", "3", "--calendar", "work@example.com", "--calendar", "Team", "--show-end")
+	require.Nil(t, command.ApplyConfig(global))
+	c := getCommandContext(t, global, "check", "--days", "5")
+	require.Nil(t, command.ApplyConfig(c))
+	// A flag given after the command name wins
+	assert.Equal(t, 5, c.Int("days"))
+	assert.Equal(t, []string{"work@example.com", "Team"}, c.StringSlice("calendar"))
+	assert.True(t, c.Bool("show-end"))
+}
+
+func TestApplyConfigFlagsBeforeCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		command string
+		err     string
+	}{
+		{"notAFlagOfTheCommand", []string{"--days", "3"}, "next", "--days can not be used with next"},
+		{"otherMeaning", []string{"--output", "json"}, "status", "--output means something else for status, give it after the command name"},
+		{"otherMeaningExport", []string{"--format", "{{.Summary}}"}, "export", "--format means something else for export, give it after the command name"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			defer setEnv(t, "XDG_CONFIG_HOME", filepath.Join(os.TempDir(), "testCalChecker"))()
+			global := getConfigContext(t, testCase.args...)
+			require.Nil(t, command.ApplyConfig(global))
+			assert.EqualError(t, command.ApplyConfig(getCommandContext(t, global, testCase.command)), testCase.err)
+		})
+	}
+}
+
+func TestApplyConfigFlagsBeforeCommandFromConfig(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	global := getConfigContext(t, "--config", writeConfig(t, testFolder, "days: 3\n"))
+	require.Nil(t, command.ApplyConfig(global))
+	// A setting for the app's flag is not a flag given before the command
+	assert.Nil(t, command.ApplyConfig(getCommandContext(t, global, "next")))
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{"unknown", "colour: blue\n", `Unknown setting "colour" in /tmp/testCalChecker/config.yaml`},
+		{"unknownCommandSetting", "export:\n  colour: blue\n", `Unknown setting "colour" for export in /tmp/testCalChecker/config.yaml`},
+		{"invalidValue", "days: many\n", `Invalid days in /tmp/testCalChecker/config.yaml: parse error`},
+		{"nestedValue", "calendar: [[a]]\n", `Invalid calendar in /tmp/testCalChecker/config.yaml: expected a value or a list of values`},
+		{"invalidYAML", "days: [\n", "Unable to parse config file /tmp/testCalChecker/config.yaml: yaml: line 1: did not find expected node content"},
+		{"invalidProfile", "profiles:\n  all: {}\n", `Invalid profile name "all" in /tmp/testCalChecker/config.yaml`},
+		{"unknownProfileSetting", "profiles:\n  work:\n    colour: blue\n", "Unable to parse the profiles in config file /tmp/testCalChecker/config.yaml: yaml: unmarshal errors:\n  line 2: field colour not found in struct command.profileConfig"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			c := getConfigContext(t, "--config", writeConfig(t, testFolder, testCase.contents))
+			err := command.ApplyConfig(c)
+			require.NotNil(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), testCase.err), err.Error())
+		})
+	}
+}
+
+func TestApplyConfigMissingFile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, "XDG_CONFIG_HOME", testFolder)()
+	assert.Nil(t, command.ApplyConfig(getConfigContext(t)))
+	assert.EqualError(
+		t,
+		command.ApplyConfig(getConfigContext(t, "--config", filepath.Join(testFolder, "missing.yaml"))),
+		"Unable to read config file: open /tmp/testCalChecker/missing.yaml: no such file or directory",
+	)
+}
+
+func TestCmdCheckConfigProfiles(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockProfileAPI(t)
+	defer api.Close()
+	writeProfileToken(t, filepath.Join(testFolder, "home.json"), "homeToken")
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work"), "workToken")
+	configFile := writeConfig(t, testFolder, `
+credentialFile: /tmp/testCalChecker/credFile
+tokenFile: /tmp/testCalChecker/tokenFile
+profile: all
+profiles:
+  home:
+    tokenFile: /tmp/testCalChecker/home.json
+  work:
+    exclude-calendar: [primary]
+`)
+	// Writes the credential file
+	getBaseAppAndFlagSet(t, testFolder, "")
+	c := getConfigContext(t, "--config", configFile)
+	require.Nil(t, command.ApplyConfig(c))
+	writer := c.App.Writer.(*bytes.Buffer)
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(c))
+	assert.Equal(t, "Wed, 10:00AM  home  homeToken event\n", writer.String())
+}
+
+func TestCmdCheckConfigProfilesWithoutToken(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockProfileAPI(t)
+	defer api.Close()
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work"), "workToken")
+	configFile := writeConfig(t, testFolder, `
+credentialFile: /tmp/testCalChecker/credFile
+tokenFile: /tmp/testCalChecker/tokenFile
+profile: all
+profiles:
+  home:
+    tokenFile: /tmp/testCalChecker/home.json
+  travel: {}
+  work: {}
+`)
+	getBaseAppAndFlagSet(t, testFolder, "")
+	c := getConfigContext(t, "--config", configFile)
+	require.Nil(t, command.ApplyConfig(c))
+	writer := c.App.Writer.(*bytes.Buffer)
+	// Authorizing home or travel would run the browser, which the test runner does not expect
+	cb := &runner.Test{}
+	assert.Nil(t, command.CmdCheck(cb)(c))
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Equal(t, "Wed, 11:00AM  workToken event\n", writer.String())
+}
+
+func TestCmdCheckConfigConflicts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings string
+		args     []string
+		expected string
+	}{
+		{"daysAndTo", "days: 7\n", []string{"--to", "tomorrow"}, "Wed Nov 8, 11:00AM  workToken event\n"},
+		{"dateAndFrom", "date: tomorrow\n", []string{"--from", "today"}, "Wed, 11:00AM  workToken event\n"},
+		{"outputAndFormat", "output: json\n", []string{"--format", "{{.Summary}}"}, "workToken event\n"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T08:00:00Z")()
+			api := getMockProfileAPI(t)
+			defer api.Close()
+			writeProfileToken(t, filepath.Join(testFolder, "tokenFile"), "workToken")
+			configFile := writeConfig(t, testFolder, `
+credentialFile: /tmp/testCalChecker/credFile
+tokenFile: /tmp/testCalChecker/tokenFile
+`+testCase.settings)
+			getBaseAppAndFlagSet(t, testFolder, "")
+			// The setting that conflicts with a flag on the command line is left out instead of failing
+			c := getConfigContext(t, append([]string{"--config", configFile}, testCase.args...)...)
+			require.Nil(t, command.ApplyConfig(c))
+			writer := c.App.Writer.(*bytes.Buffer)
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(c))
+			assert.Equal(t, testCase.expected, writer.String())
+		})
+	}
+}
+
+// getConfigContext parses args with flags and commands like the app's, with the timezone flag read from
+// $CALCHECKER_TEST_TIMEZONE
+func getConfigContext(t *testing.T, args ...string) *cli.Context {
+	app, _ := appWithTestWriters()
+	outputFlags := []cli.Flag{
+		cli.StringFlag{Name: "output", Value: "text"},
+		cli.StringFlag{Name: "format"},
+		cli.StringFlag{Name: "template-file"},
+		cli.BoolFlag{Name: "show-end"},
+	}
+	dateFlags := []cli.Flag{
+		cli.StringFlag{Name: "date"},
+		cli.StringFlag{Name: "from"},
+		cli.StringFlag{Name: "to"},
+		cli.IntFlag{Name: "days", Value: 1},
+	}
+	calendarFlags := []cli.Flag{
+		cli.StringFlag{Name: "timezone", EnvVar: "CALCHECKER_TEST_TIMEZONE"},
+		cli.StringSliceFlag{Name: "calendar"},
+		cli.StringSliceFlag{Name: "exclude-calendar"},
+		cli.IntFlag{Name: "concurrency", Value: 4},
+	}
+	checkFlags := append(append(append([]cli.Flag{}, outputFlags...), dateFlags...), calendarFlags...)
+	app.Flags = append(
+		[]cli.Flag{
+			cli.StringFlag{Name: "config"},
+			cli.StringFlag{Name: "credentialFile"},
+			cli.StringFlag{Name: "tokenFile"},
+			cli.StringFlag{Name: "profile"},
+		},
+		checkFlags...,
+	)
+	app.Commands = []cli.Command{
+		{Name: "check", Flags: checkFlags},
+		{
+			Name: "export",
+			Flags: append(
+				[]cli.Flag{cli.StringFlag{Name: "format", Value: "ics"}, cli.StringFlag{Name: "file"}, cli.BoolFlag{Name: "recurring"}},
+				append(append([]cli.Flag{}, dateFlags...), calendarFlags...)...,
+			),
+		},
+		{
+			Name:  "next",
+			Flags: append(append([]cli.Flag{cli.IntFlag{Name: "count", Value: 5}, cli.DurationFlag{Name: "within"}}, outputFlags...), calendarFlags...),
+		},
+		{
+			Name: "status",
+			Flags: append(
+				[]cli.Flag{
+					cli.IntFlag{Name: "max-length", Value: 60},
+					cli.StringFlag{Name: "output", Value: "text", Usage: "text, waybar or i3bar"},
+					cli.BoolFlag{Name: "interactive"},
+					cli.DurationFlag{Name: "cache-ttl", Value: time.Minute},
+					cli.DurationFlag{Name: "interval", Value: 30 * time.Second},
+				},
+				calendarFlags...,
+			),
+		},
+	}
+	set := flag.NewFlagSet("test", 0)
+	for _, appFlag := range app.Flags {
+		appFlag.Apply(set)
+	}
+
+	require.Nil(t, set.Parse(args))
+	return cli.NewContext(app, set, nil)
+}
+
+// getCommandContext parses args with the flags of the command name, as run after the app's context global
+func getCommandContext(t *testing.T, global *cli.Context, name string, args ...string) *cli.Context {
+	appCommand := global.App.Command(name)
+	set := flag.NewFlagSet(name, 0)
+	for _, commandFlag := range appCommand.Flags {
+		commandFlag.Apply(set)
+	}
+
+	require.Nil(t, set.Parse(args))
+	c := cli.NewContext(global.App, set, global)
+	c.Command = *appCommand
+	return c
+}
+
+// getAuthorizedCommandContext gives args before the command name, with an empty config and an authorized token
+func getAuthorizedCommandContext(t *testing.T, testFolder, name string, args ...string) *cli.Context {
+	getAuthorizedAppAndFlagSet(t, testFolder)
+	global := getConfigContext(
+		t,
+		append(
+			[]string{
+				"--config", writeConfig(t, testFolder, ""),
+				"--credentialFile", filepath.Join(testFolder, "credFile"),
+				"--tokenFile", filepath.Join(testFolder, "tokenFile"),
+			},
+			args...,
+		)...,
+	)
+	require.Nil(t, command.ApplyConfig(global))
+	c := getCommandContext(t, global, name)
+	require.Nil(t, command.ApplyConfig(c))
+	return c
+}
+
+func writeConfig(t *testing.T, testFolder, contents string) string {
+	configFile := filepath.Join(testFolder, "config.yaml")
+	assert.Nil(t, ioutil.WriteFile(configFile, []byte(contents), 0600))
+	return configFile
+}
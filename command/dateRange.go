@@ -0,0 +1,114 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// Now allows overriding the current time for testing
+var Now = time.Now
+
+const dateFormat = "2006-01-02"
+
+// DefaultDays is the number of days checked without --days or --to
+const DefaultDays = 1
+
+// dateRange is a span of whole days starting at midnight on start and ending at midnight on end.
+// Because of DST changes a day is not always 24 hours long.
+type dateRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// days returns the number of days covered by the range
+func (r dateRange) days() int {
+	return int(r.end.Sub(r.start).Hours()+12) / 24
+}
+
+// parseDateRange builds the requested range from the --date, --from, --to and --days flags.
+// Days start at midnight in loc.
+func parseDateRange(c *cli.Context, loc *time.Location) (dateRange, error) {
+	now := Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if c.String("date") != "" && (c.String("from") != "" || c.String("to") != "") {
+		return dateRange{}, cli.NewExitError("You cannot specify --date with --from or --to", 1)
+	}
+
+	if c.String("to") != "" && c.IsSet("days") {
+		return dateRange{}, cli.NewExitError("You cannot specify both --to and --days", 1)
+	}
+
+	if c.IsSet("days") && c.Int("days") < 1 {
+		return dateRange{}, cli.NewExitError("--days must be at least 1", 1)
+	}
+
+	startValue := c.String("date")
+	if startValue == "" {
+		startValue = c.String("from")
+	}
+
+	start := today
+	if startValue != "" {
+		var err error
+		start, err = parseDate(startValue, today)
+		if err != nil {
+			return dateRange{}, err
+		}
+	}
+
+	days := DefaultDays
+	if c.IsSet("days") {
+		days = c.Int("days")
+	}
+
+	end := start.AddDate(0, 0, days)
+	if c.String("to") != "" {
+		to, err := parseDate(c.String("to"), today)
+		if err != nil {
+			return dateRange{}, err
+		}
+
+		if to.Before(start) {
+			return dateRange{}, cli.NewExitError(fmt.Sprintf("--to (%s) is before the start of the range (%s)", to.Format(dateFormat), start.Format(dateFormat)), 1)
+		}
+
+		end = to.AddDate(0, 0, 1)
+	}
+
+	return dateRange{start: start, end: end}, nil
+}
+
+// parseDate parses an absolute date (2006-01-02) or a relative one like "tomorrow" or "next monday"
+func parseDate(value string, today time.Time) (time.Time, error) {
+	normalized := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "next ")
+	switch normalized {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if normalized == name || normalized == name[:3] {
+			offset := (int(weekday) - int(today.Weekday()) + 7) % 7
+			if offset == 0 {
+				offset = 7
+			}
+
+			return today.AddDate(0, 0, offset), nil
+		}
+	}
+
+	date, err := time.ParseInLocation(dateFormat, value, today.Location())
+	if err != nil {
+		return time.Time{}, cli.NewExitError(fmt.Sprintf("Invalid date %q, expected YYYY-MM-DD, today, tomorrow, yesterday or a weekday", value), 1)
+	}
+
+	return date, nil
+}
@@ -0,0 +1,111 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckDateRange(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    map[string]string
+		timeMin string
+		timeMax string
+	}{
+		{"default", nil, "2017-11-08T00:00:00Z", "2017-11-09T00:00:00Z"},
+		{"date", map[string]string{"date": "2017-12-25"}, "2017-12-25T00:00:00Z", "2017-12-26T00:00:00Z"},
+		{"tomorrow", map[string]string{"date": "tomorrow"}, "2017-11-09T00:00:00Z", "2017-11-10T00:00:00Z"},
+		{"yesterday", map[string]string{"date": "Yesterday"}, "2017-11-07T00:00:00Z", "2017-11-08T00:00:00Z"},
+		{"next monday", map[string]string{"date": "next monday"}, "2017-11-13T00:00:00Z", "2017-11-14T00:00:00Z"},
+		{"same weekday", map[string]string{"date": "wed"}, "2017-11-15T00:00:00Z", "2017-11-16T00:00:00Z"},
+		{"days", map[string]string{"days": "7"}, "2017-11-08T00:00:00Z", "2017-11-15T00:00:00Z"},
+		{"from days", map[string]string{"from": "friday", "days": "3"}, "2017-11-10T00:00:00Z", "2017-11-13T00:00:00Z"},
+		{"from to", map[string]string{"from": "2017-11-01", "to": "2017-11-03"}, "2017-11-01T00:00:00Z", "2017-11-04T00:00:00Z"},
+		{"to", map[string]string{"to": "tomorrow"}, "2017-11-08T00:00:00Z", "2017-11-10T00:00:00Z"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T10:00:00Z")()
+			api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "UTC"}}, nil)
+			defer api.Close()
+			app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			for name, value := range testCase.args {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.timeMin, api.query("primary").Get("timeMin"))
+			assert.Equal(t, testCase.timeMax, api.query("primary").Get("timeMax"))
+		})
+	}
+}
+
+func TestCmdCheckDateRangeMultiDayOutput(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T10:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, Summary: "Standup"},
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-09T15:30:00Z"}, Summary: "Retro"},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("days", "2"))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Wed Nov 8, 9:00AM  Standup\nThu Nov 9, 3:30PM  Retro\n", writer.String())
+}
+
+func TestCmdCheckDateRangeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  map[string]string
+		error string
+	}{
+		{"date and from", map[string]string{"date": "today", "from": "today"}, "You cannot specify --date with --from or --to"},
+		{"date and to", map[string]string{"date": "today", "to": "tomorrow"}, "You cannot specify --date with --from or --to"},
+		{"to and days", map[string]string{"to": "tomorrow", "days": "2"}, "You cannot specify both --to and --days"},
+		{"zero days", map[string]string{"days": "0"}, "--days must be at least 1"},
+		{"invalid date", map[string]string{"date": "someday"}, `Invalid date "someday", expected YYYY-MM-DD, today, tomorrow, yesterday or a weekday`},
+		{"invalid to", map[string]string{"to": "11/09/2017"}, `Invalid date "11/09/2017", expected YYYY-MM-DD, today, tomorrow, yesterday or a weekday`},
+		{"to before from", map[string]string{"from": "2017-11-08", "to": "2017-11-07"}, "--to (2017-11-07) is before the start of the range (2017-11-08)"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T10:00:00Z")()
+			app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+			addCheckFlags(set)
+			for name, value := range testCase.args {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			cb := &runner.Test{}
+			assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), testCase.error)
+			assert.Equal(t, []error(nil), cb.Errors)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DeviceAuthURL is the OAuth device authorization endpoint, it allows overriding the endpoint for testing
+var DeviceAuthURL = "https://oauth2.googleapis.com/device/code"
+
+// DevicePollInterval is the length of one second of the polling interval requested by the server,
+// it allows speeding up polling for testing
+var DevicePollInterval = time.Second
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceScopeError explains the invalid_scope error Google gives because its device flow only allows a few scopes,
+// which do not include Google Calendar
+const deviceScopeError = "Google does not allow access to Google Calendar with the device flow, " +
+	"use --no-browser to authorize with a browser on another computer instead"
+
+// deviceCode is the response to a device authorization request
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	// Google names the verification URI differently than RFC 8628
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// deviceTokenResponse is a response from the token endpoint while polling
+type deviceTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// getTokenFromDevice uses the OAuth 2.0 device authorization grant to request a Token.
+// The user authorizes calChecker on another device, so no browser or redirect is needed on this one.
+func (client Client) getTokenFromDevice(writer io.Writer) (*oauth2.Token, error) {
+	code, err := client.requestDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	verificationURL := code.VerificationURI
+	if verificationURL == "" {
+		verificationURL = code.VerificationURL
+	}
+
+	fmt.Fprintf(writer, "Visit %s and enter the code %s\n", verificationURL, code.UserCode)
+	interval := code.Interval
+	if interval <= 0 {
+		interval = 5
+	}
+
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * DevicePollInterval)
+	for {
+		time.Sleep(time.Duration(interval) * DevicePollInterval)
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("The device code expired before authorization was completed")
+		}
+
+		response, err := client.pollDeviceToken(code.DeviceCode)
+		if err != nil {
+			return nil, err
+		}
+
+		switch response.Error {
+		case "":
+			return response.token(), nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5
+		case "access_denied":
+			return nil, fmt.Errorf("Authorization failed: access_denied")
+		case "expired_token":
+			return nil, fmt.Errorf("The device code expired before authorization was completed")
+		case "invalid_scope":
+			return nil, fmt.Errorf(deviceScopeError)
+		default:
+			return nil, fmt.Errorf("Unable to retrieve token from device: %s", response.describeError())
+		}
+	}
+}
+
+func (client Client) requestDeviceCode() (*deviceCode, error) {
+	response, err := http.PostForm(DeviceAuthURL, url.Values{
+		"client_id": {client.config.ClientID},
+		"scope":     {strings.Join(client.config.Scopes, " ")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start device authorization: %v", err)
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		failure := &deviceTokenResponse{}
+		_ = json.NewDecoder(response.Body).Decode(failure)
+		if failure.Error == "invalid_scope" {
+			return nil, fmt.Errorf(deviceScopeError)
+		}
+
+		return nil, fmt.Errorf("Unable to start device authorization: %s %s", response.Status, failure.describeError())
+	}
+
+	code := &deviceCode{}
+	err = json.NewDecoder(response.Body).Decode(code)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start device authorization: %v", err)
+	}
+
+	return code, nil
+}
+
+// pollDeviceToken asks the token endpoint whether the user has finished authorizing the device
+func (client Client) pollDeviceToken(deviceCode string) (*deviceTokenResponse, error) {
+	response, err := http.PostForm(client.config.Endpoint.TokenURL, url.Values{
+		"client_id":     {client.config.ClientID},
+		"client_secret": {client.config.ClientSecret},
+		"device_code":   {deviceCode},
+		"grant_type":    {deviceGrantType},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve token from device: %v", err)
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	token := &deviceTokenResponse{}
+	err = json.NewDecoder(response.Body).Decode(token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve token from device: %s: %v", response.Status, err)
+	}
+
+	if token.Error == "" && (response.StatusCode != http.StatusOK || token.AccessToken == "") {
+		return nil, fmt.Errorf("Unable to retrieve token from device: %s", response.Status)
+	}
+
+	return token, nil
+}
+
+func (response *deviceTokenResponse) token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  response.AccessToken,
+		TokenType:    response.TokenType,
+		RefreshToken: response.RefreshToken,
+	}
+
+	if response.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+
+	return token
+}
+
+func (response *deviceTokenResponse) describeError() string {
+	if response.ErrorDescription == "" {
+		return response.Error
+	}
+
+	return fmt.Sprintf("%s (%s)", response.Error, response.ErrorDescription)
+}
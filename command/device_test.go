@@ -0,0 +1,178 @@
+package command_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+// mockDeviceAPI is a stand in for the device authorization and token endpoints.
+// Each poll of the token endpoint gets the next of responses.
+type mockDeviceAPI struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	responses []string
+	polls     []time.Time
+}
+
+func getMockDeviceAPI(t *testing.T, responses ...string) *mockDeviceAPI {
+	api := &mockDeviceAPI{responses: responses}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/device/code" {
+			assert.Equal(t, "id", r.FormValue("client_id"))
+			assert.Equal(t, "https://www.googleapis.com/auth/calendar.readonly", r.FormValue("scope"))
+			_, err := w.Write([]byte(`{"device_code":"device","user_code":"ABCD-EFGH","verification_url":"https://www.google.com/device","expires_in":1800,"interval":1}`))
+			assert.Nil(t, err)
+			return
+		}
+
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", r.FormValue("grant_type"))
+		assert.Equal(t, "device", r.FormValue("device_code"))
+		assert.Equal(t, "secret", r.FormValue("client_secret"))
+		api.mutex.Lock()
+		defer api.mutex.Unlock()
+		api.polls = append(api.polls, time.Now())
+		response := api.responses[0]
+		api.responses = api.responses[1:]
+		var decoded map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(response), &decoded))
+		if _, ok := decoded["error"]; ok {
+			w.WriteHeader(http.StatusPreconditionRequired)
+		}
+
+		_, err := w.Write([]byte(response))
+		assert.Nil(t, err)
+	}))
+	command.DeviceAuthURL = api.URL + "/device/code"
+	command.DevicePollInterval = time.Millisecond
+	return api
+}
+
+func (api *mockDeviceAPI) Close() {
+	api.Server.Close()
+	command.DeviceAuthURL = "https://oauth2.googleapis.com/device/code"
+	command.DevicePollInterval = time.Second
+}
+
+func TestCmdAuthLoginDevice(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockDeviceAPI(
+		t,
+		`{"error":"authorization_pending"}`,
+		`{"error":"slow_down"}`,
+		`{"error":"authorization_pending"}`,
+		`{"access_token":"deviceToken","refresh_token":"deviceRefresh","token_type":"Bearer","expires_in":3600}`,
+	)
+	defer api.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+	set.Bool("device", true, "doc")
+	cb := &runner.Test{}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		"Visit https://www.google.com/device and enter the code ABCD-EFGH\nAuthorized, the token was saved to /tmp/testCalChecker/tokenFile\n",
+		writer.String(),
+	)
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Len(t, api.polls, 4)
+	assert.True(t, api.polls[2].Sub(api.polls[1]) >= 6*time.Millisecond)
+	token := readTokenFile(t, filepath.Join(testFolder, "tokenFile"))
+	assert.Equal(t, "deviceToken", token.AccessToken)
+	assert.Equal(t, "deviceRefresh", token.RefreshToken)
+	assert.True(t, token.Expiry.After(time.Now()))
+}
+
+func TestCmdAuthLoginDeviceFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		expected string
+	}{
+		{"denied", `{"error":"access_denied"}`, "Could not get OAuth token: Authorization failed: access_denied"},
+		{"expired", `{"error":"expired_token"}`, "Could not get OAuth token: The device code expired before authorization was completed"},
+		{
+			"invalid scope",
+			`{"error":"invalid_scope"}`,
+			"Could not get OAuth token: Google does not allow access to Google Calendar with the device flow, " +
+				"use --no-browser to authorize with a browser on another computer instead",
+		},
+		{
+			"unknown error",
+			`{"error":"invalid_client","error_description":"The OAuth client was not found."}`,
+			"Could not get OAuth token: Unable to retrieve token from device: invalid_client (The OAuth client was not found.)",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			api := getMockDeviceAPI(t, `{"error":"authorization_pending"}`, testCase.response)
+			defer api.Close()
+			app, _, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+			set.Bool("device", true, "doc")
+			assert.EqualError(t, command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)), testCase.expected)
+			_, err := os.Stat(filepath.Join(testFolder, "tokenFile"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestCmdAuthLoginDeviceUnsupportedClient(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockDeviceAPI(t)
+	defer api.Close()
+	unsupported := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, err := w.Write([]byte(`{"error":"invalid_client","error_description":"Invalid client type."}`))
+		assert.Nil(t, err)
+	}))
+	defer unsupported.Close()
+	command.DeviceAuthURL = unsupported.URL
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+	set.Bool("device", true, "doc")
+	assert.EqualError(
+		t,
+		command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Could not get OAuth token: Unable to start device authorization: 401 Unauthorized invalid_client (Invalid client type.)",
+	)
+}
+
+func TestCmdAuthLoginDeviceInvalidScope(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockDeviceAPI(t)
+	defer api.Close()
+	invalidScope := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, err := w.Write([]byte(`{"error":"invalid_scope"}`))
+		assert.Nil(t, err)
+	}))
+	defer invalidScope.Close()
+	command.DeviceAuthURL = invalidScope.URL
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, api.URL)
+	set.Bool("device", true, "doc")
+	assert.EqualError(
+		t,
+		command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Could not get OAuth token: Google does not allow access to Google Calendar with the device flow, "+
+			"use --no-browser to authorize with a browser on another computer instead",
+	)
+}
@@ -0,0 +1,193 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Event is a calendar event normalized for rendering
+type Event struct {
+	Profile        string
+	CalendarID     string
+	CalendarLabel  string
+	ID             string
+	ICalUID        string
+	Summary        string
+	Start          time.Time
+	End            time.Time
+	AllDay         bool
+	Location       string
+	Status         string
+	ResponseStatus string
+	ConferenceLink string
+	Attendees      []string
+	Description    string
+	Recurrence     []string
+
+	// These keep details needed by exports
+	attendees     []*calendar.EventAttendee
+	timeZone      string
+	originalStart time.Time
+}
+
+// Duration is how long the event lasts
+func (event *Event) Duration() time.Duration {
+	return event.End.Sub(event.Start)
+}
+
+// newEvent converts an API event into an Event.  Times are converted to loc and dates without a time are midnight in loc.
+func newEvent(item *calendar.CalendarListEntry, event *calendar.Event, loc *time.Location) (*Event, error) {
+	start, allDay, err := parseEventTime(event.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid start time for event %q: %v", event.Summary, err)
+	}
+
+	end, _, err := parseEventTime(event.End, loc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid end time for event %q: %v", event.Summary, err)
+	}
+
+	if allDay && !end.After(start) {
+		// The end date of an all day event is exclusive so a missing end means a single day
+		end = start.AddDate(0, 0, 1)
+	} else if end.IsZero() {
+		end = start
+	}
+
+	var originalStart time.Time
+	if event.RecurringEventId != "" {
+		originalStart, _, err = parseEventTime(event.OriginalStartTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid original start time for event %q: %v", event.Summary, err)
+		}
+	}
+
+	timeZone := ""
+	if event.Start != nil {
+		timeZone = event.Start.TimeZone
+	}
+
+	return &Event{
+		CalendarID:     item.Id,
+		CalendarLabel:  calendarLabel(item),
+		ID:             event.Id,
+		ICalUID:        event.ICalUID,
+		Summary:        event.Summary,
+		Start:          start,
+		End:            end,
+		AllDay:         allDay,
+		Location:       event.Location,
+		Status:         event.Status,
+		ResponseStatus: selfResponseStatus(event),
+		ConferenceLink: event.HangoutLink,
+		Attendees:      attendeeNames(event),
+		Description:    event.Description,
+		Recurrence:     event.Recurrence,
+		attendees:      event.Attendees,
+		timeZone:       timeZone,
+		originalStart:  originalStart,
+	}, nil
+}
+
+// attendeeNames lists the people invited to the event, skipping rooms and other resources
+func attendeeNames(event *calendar.Event) []string {
+	names := []string{}
+	for _, attendee := range event.Attendees {
+		if attendee.Resource {
+			continue
+		}
+
+		if attendee.DisplayName != "" {
+			names = append(names, attendee.DisplayName)
+		} else {
+			names = append(names, attendee.Email)
+		}
+	}
+
+	return names
+}
+
+// selfResponseStatus returns how the calendar's owner responded to the event
+func selfResponseStatus(event *calendar.Event) string {
+	for _, attendee := range event.Attendees {
+		if attendee.Self {
+			return attendee.ResponseStatus
+		}
+	}
+
+	return ""
+}
+
+// parseEventTime parses either the DateTime or the Date of value and reports whether it was a date without a time
+func parseEventTime(value *calendar.EventDateTime, loc *time.Location) (time.Time, bool, error) {
+	if value == nil {
+		return time.Time{}, true, nil
+	}
+
+	if value.DateTime != "" {
+		parsed, err := time.Parse(time.RFC3339, value.DateTime)
+		return parsed.In(loc), false, err
+	}
+
+	if value.Date != "" {
+		parsed, err := time.ParseInLocation(dateFormat, value.Date, loc)
+		return parsed, true, err
+	}
+
+	return time.Time{}, true, nil
+}
+
+// sortEvents orders events by start time with all day events first.  Ties keep the order of their calendars.
+func sortEvents(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+
+		return events[i].AllDay && !events[j].AllDay
+	})
+}
+
+// inRange removes all day events that don't have any days in window.
+// The API can return these when the calendar's timezone differs from the one used for the range.
+// Recurring events that were not expanded are kept, since their times are those of the first occurrence.
+func inRange(events []*Event, window dateRange) []*Event {
+	filtered := make([]*Event, 0, len(events))
+	for _, event := range events {
+		if event.AllDay && len(event.Recurrence) == 0 && ((!window.end.IsZero() && !event.Start.Before(window.end)) || !event.End.After(window.start)) {
+			continue
+		}
+
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
+
+// dedupeEvents removes events that appear on more than one calendar, keeping the first copy
+func dedupeEvents(events []*Event) []*Event {
+	seen := map[string]bool{}
+	deduped := make([]*Event, 0, len(events))
+	for _, event := range events {
+		id := event.ICalUID
+		if id == "" {
+			id = event.ID
+		}
+
+		if id != "" {
+			key := fmt.Sprintf("%s@%d", id, event.Start.Unix())
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+		}
+
+		deduped = append(deduped, event)
+	}
+
+	return deduped
+}
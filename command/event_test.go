@@ -0,0 +1,116 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckSortsAndAlignsAcrossPages(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-08T15:00:00Z"}, Summary: "Retro"},
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, Summary: "Standup"},
+				{Start: &calendar.EventDateTime{Date: "2017-11-08"}, Summary: "Offsite"},
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-08T11:30:00Z"}, Summary: "Lunch"},
+			},
+		},
+	)
+	defer api.Close()
+	api.pageSize = 1
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "All Day       Offsite\nWed, 9:00AM   Standup\nWed, 11:30AM  Lunch\nWed, 3:00PM   Retro\n", writer.String())
+}
+
+func TestCmdCheckDedupesEventsOnMultipleCalendars(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{
+			{Id: "me", Summary: "Me", Primary: true, Selected: true, TimeZone: "UTC"},
+			{Id: "team", Summary: "Team", Selected: true},
+		},
+		map[string][]*calendar.Event{
+			"me": {
+				{Id: "a", ICalUID: "planning@example.com", Start: &calendar.EventDateTime{DateTime: "2017-11-08T13:00:00Z"}, Summary: "Planning"},
+				{Id: "b", ICalUID: "standup@example.com", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, Summary: "Standup"},
+			},
+			"team": {
+				{Id: "c", ICalUID: "planning@example.com", Start: &calendar.EventDateTime{DateTime: "2017-11-08T13:00:00Z"}, Summary: "Planning"},
+				{Id: "d", ICalUID: "standup@example.com", Start: &calendar.EventDateTime{DateTime: "2017-11-09T09:00:00Z"}, Summary: "Standup"},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("days", "2"))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Wed Nov 8, 9:00AM  Me    Standup\nWed Nov 8, 1:00PM  Me    Planning\nThu Nov 9, 9:00AM  Team  Standup\n", writer.String())
+}
+
+func TestCmdCheckInvalidEventTime(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Start: &calendar.EventDateTime{DateTime: "tomorrow at noon"}, Summary: "Lunch"},
+			},
+		},
+	)
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		`Invalid start time for event "Lunch": parsing time "tomorrow at noon" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow at noon" as "2006"`,
+	)
+}
+
+func TestCmdCheckAllDayEvents(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T20:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "America/Los_Angeles"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Start: &calendar.EventDateTime{Date: "2017-11-07"}, End: &calendar.EventDateTime{Date: "2017-11-08"}, Summary: "Yesterday"},
+				{Start: &calendar.EventDateTime{Date: "2017-11-08"}, Summary: "No end"},
+				{Start: &calendar.EventDateTime{Date: "2017-11-09"}, End: &calendar.EventDateTime{Date: "2017-11-10"}, Summary: "Tomorrow"},
+				{Start: &calendar.EventDateTime{Date: "2017-11-06"}, End: &calendar.EventDateTime{Date: "2017-11-12"}, Summary: "Conference"},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Day 3 of 6  Conference\nAll Day     No end\n", writer.String())
+}
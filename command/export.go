@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdExport writes the events for a range of days in iCalendar format
+func CmdExport(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker export\"", 1)
+		}
+
+		if c.String("format") != "" && c.String("format") != "ics" {
+			return cli.NewExitError(fmt.Sprintf("Invalid export format %q, expected ics", c.String("format")), 1)
+		}
+
+		options, err := parseAgendaFlags(c)
+		if err != nil {
+			return err
+		}
+
+		options.expandRecurring = !c.Bool("recurring")
+		agenda, err := loadAgenda(c, cmdBuilder, options)
+		if err != nil {
+			return err
+		}
+
+		renderer := icsRenderer{loc: agenda.window.start.Location()}
+		if c.String("file") == "" {
+			return renderer.render(c.App.Writer, agenda.events)
+		}
+
+		return writeFile(c.String("file"), func(w io.Writer) error {
+			return renderer.render(w, agenda.events)
+		})
+	}
+}
+
+// writeFile creates fileName and fills it using write
+func writeFile(fileName string, write func(io.Writer) error) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("Unable to create export file: %v", err)
+	}
+
+	err = write(f)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
@@ -0,0 +1,272 @@
+package command_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestCmdExport(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T13:00:00Z")()
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), getExportTestEvents())
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addExportFlags(set)
+	assert.Nil(t, command.CmdExport(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "true", api.query("me@example.com").Get("singleEvents"))
+	assertGolden(t, "export.ics", writer.String())
+}
+
+func TestCmdExportRecurring(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T13:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "me@example.com", Primary: true, Selected: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{
+			"me@example.com": {
+				{
+					Id:         "standup",
+					ICalUID:    "standup@google.com",
+					Summary:    "Standup",
+					Start:      &calendar.EventDateTime{DateTime: "2017-11-06T09:00:00-05:00", TimeZone: "America/New_York"},
+					End:        &calendar.EventDateTime{DateTime: "2017-11-06T09:15:00-05:00", TimeZone: "America/New_York"},
+					Recurrence: []string{"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "EXDATE;TZID=America/New_York:20171123T090000"},
+				},
+			},
+		},
+	)
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addExportFlags(set)
+	outputFile := filepath.Join(testFolder, "agenda.ics")
+	assert.Nil(t, set.Set("recurring", "true"))
+	assert.Nil(t, set.Set("file", outputFile))
+	assert.Nil(t, command.CmdExport(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "false", api.query("me@example.com").Get("singleEvents"))
+	contents, err := ioutil.ReadFile(outputFile)
+	assert.Nil(t, err)
+	output := string(contents)
+	zoneStart := strings.Index(output, "BEGIN:VTIMEZONE\r\n")
+	zoneEnd := strings.Index(output, "END:VTIMEZONE\r\n") + len("END:VTIMEZONE\r\n")
+	require.True(t, zoneStart >= 0 && zoneEnd > zoneStart)
+	zone := output[zoneStart:zoneEnd]
+	assert.True(t, strings.HasPrefix(zone, "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\nBEGIN:DAYLIGHT\r\nDTSTART:20170312T020000\r\n"), zone)
+	// The zone covers the next 5 years for the series' later occurrences
+	assert.Contains(t, zone, "BEGIN:STANDARD\r\nDTSTART:20221106T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\nTZNAME:EST\r\nEND:STANDARD\r\n")
+	assert.Equal(t, 12, strings.Count(zone, "BEGIN:DAYLIGHT")+strings.Count(zone, "BEGIN:STANDARD"))
+	assert.Equal(
+		t,
+		"BEGIN:VCALENDAR\r\n"+
+			"VERSION:2.0\r\n"+
+			"PRODID:-//guywithnose//calChecker "+command.Version+"//EN\r\n"+
+			"CALSCALE:GREGORIAN\r\n"+
+			"BEGIN:VEVENT\r\n"+
+			"UID:standup@google.com\r\n"+
+			"DTSTAMP:20171108T130000Z\r\n"+
+			"DTSTART;TZID=America/New_York:20171106T090000\r\n"+
+			"DTEND;TZID=America/New_York:20171106T091500\r\n"+
+			"SUMMARY:Standup\r\n"+
+			"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR\r\n"+
+			"EXDATE;TZID=America/New_York:20171123T090000\r\n"+
+			"END:VEVENT\r\n"+
+			"END:VCALENDAR\r\n",
+		output[:zoneStart]+output[zoneEnd:],
+	)
+}
+
+func TestCmdExportRecurringAllDay(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T13:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "me@example.com", Primary: true, Selected: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{
+			"me@example.com": {
+				{
+					Id:         "birthday",
+					Summary:    "Birthday",
+					Start:      &calendar.EventDateTime{Date: "2010-11-08"},
+					End:        &calendar.EventDateTime{Date: "2010-11-09"},
+					Recurrence: []string{"RRULE:FREQ=YEARLY"},
+				},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addExportFlags(set)
+	assert.Nil(t, set.Set("recurring", "true"))
+	assert.Nil(t, command.CmdExport(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		"BEGIN:VCALENDAR\r\n"+
+			"VERSION:2.0\r\n"+
+			"PRODID:-//guywithnose//calChecker "+command.Version+"//EN\r\n"+
+			"CALSCALE:GREGORIAN\r\n"+
+			"BEGIN:VEVENT\r\n"+
+			"UID:birthday@google.com\r\n"+
+			"DTSTAMP:20171108T130000Z\r\n"+
+			"DTSTART;VALUE=DATE:20101108\r\n"+
+			"DTEND;VALUE=DATE:20101109\r\n"+
+			"SUMMARY:Birthday\r\n"+
+			"RRULE:FREQ=YEARLY\r\n"+
+			"END:VEVENT\r\n"+
+			"END:VCALENDAR\r\n",
+		writer.String(),
+	)
+}
+
+func TestCmdExportZoneWithoutTransitions(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T00:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "me@example.com", Primary: true, Selected: true, TimeZone: "Asia/Tokyo"}},
+		map[string][]*calendar.Event{
+			"me@example.com": {
+				{
+					Id:      "lunch",
+					Summary: "Lunch",
+					Start:   &calendar.EventDateTime{DateTime: "2017-11-08T12:00:00+09:00", TimeZone: "Asia/Tokyo"},
+					End:     &calendar.EventDateTime{DateTime: "2017-11-08T13:00:00+09:00", TimeZone: "Asia/Tokyo"},
+				},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addExportFlags(set)
+	assert.Nil(t, command.CmdExport(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Contains(
+		t,
+		writer.String(),
+		"BEGIN:VTIMEZONE\r\n"+
+			"TZID:Asia/Tokyo\r\n"+
+			"BEGIN:STANDARD\r\n"+
+			"DTSTART:19700101T000000\r\n"+
+			"TZOFFSETFROM:+0900\r\n"+
+			"TZOFFSETTO:+0900\r\n"+
+			"TZNAME:JST\r\n"+
+			"END:STANDARD\r\n"+
+			"END:VTIMEZONE\r\n"+
+			"BEGIN:VEVENT\r\n",
+	)
+	assert.Contains(t, writer.String(), "DTSTART;TZID=Asia/Tokyo:20171108T120000\r\n")
+}
+
+func TestCmdExportUsage(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addExportFlags(set)
+	assert.Nil(t, set.Parse([]string{"foo"}))
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdExport(cb)(cli.NewContext(app, set, nil)), `Usage: "calChecker export"`)
+}
+
+func TestCmdExportInvalidFormat(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addExportFlags(set)
+	assert.Nil(t, set.Set("format", "csv"))
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdExport(cb)(cli.NewContext(app, set, nil)), `Invalid export format "csv", expected ics`)
+}
+
+func TestCmdExportInvalidFile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addExportFlags(set)
+	assert.Nil(t, set.Set("file", "/doesNotExist/agenda.ics"))
+	assert.EqualError(
+		t,
+		command.CmdExport(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Unable to create export file: open /doesNotExist/agenda.ics: no such file or directory",
+	)
+}
+
+func addExportFlags(set *flag.FlagSet) {
+	addCheckFlags(set)
+	set.Bool("recurring", false, "doc")
+	set.String("file", "", "doc")
+}
+
+func getExportTestEvents() map[string][]*calendar.Event {
+	return map[string][]*calendar.Event{
+		"me@example.com": {
+			{
+				Id:          "standup_20171108T140000Z",
+				ICalUID:     "standup@google.com",
+				Summary:     "Standup",
+				Description: "Daily sync; bring updates, blockers\nand questions. This description is long enough that it has to be folded across lines.",
+				Start:       &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00-05:00"},
+				End:         &calendar.EventDateTime{DateTime: "2017-11-08T09:15:00-05:00"},
+				Location:    "Room 1, Floor 2",
+				Status:      "confirmed",
+				Attendees: []*calendar.EventAttendee{
+					{Email: "boss@example.com", DisplayName: "Boss, The", ResponseStatus: "accepted"},
+					{Email: "me@example.com", Self: true, ResponseStatus: "tentative", Optional: true},
+					{DisplayName: "Room 1", Resource: true},
+				},
+				RecurringEventId:  "standup",
+				OriginalStartTime: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00-05:00"},
+			},
+			{
+				Id:      "call",
+				Summary: "Call with London – ☎",
+				Start:   &calendar.EventDateTime{DateTime: "2017-11-08T16:00:00Z", TimeZone: "Europe/London"},
+				End:     &calendar.EventDateTime{DateTime: "2017-11-08T16:30:00Z", TimeZone: "Europe/London"},
+			},
+		},
+		"team@example.com": {
+			{
+				Id:      "offsite",
+				ICalUID: "offsite@google.com",
+				Summary: "Offsite",
+				Start:   &calendar.EventDateTime{Date: "2017-11-07"},
+				End:     &calendar.EventDateTime{Date: "2017-11-10"},
+				Status:  "tentative",
+			},
+		},
+	}
+}
+
+func TestCmdExportFlagsBeforeCommand(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	c := getAuthorizedCommandContext(t, testFolder, "export", "--days", "0")
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdExport(cb)(c), "--days must be at least 1")
+	assert.Equal(t, []error(nil), cb.Errors)
+}
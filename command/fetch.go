@@ -0,0 +1,144 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// eventQuery chooses which events are listed from each calendar
+type eventQuery struct {
+	window dateRange
+	// expandRecurring lists each occurrence of a recurring event instead of the event with its recurrence rules
+	expandRecurring bool
+	// from and until narrow the window to the events overlapping them when they are set.
+	// A window without an end is only limited by until and limit.
+	from  time.Time
+	until time.Time
+	// limit lists only the first events by start time, 0 lists them all
+	limit int
+}
+
+// truncate drops the events after the query's limit
+func (query eventQuery) truncate(events []*Event) []*Event {
+	if query.limit > 0 && len(events) > query.limit {
+		return events[:query.limit]
+	}
+
+	return events
+}
+
+// collectEvents fetches the events matching query from every calendar and returns them sorted with duplicates removed
+func collectEvents(srv *calendar.Service, calendars []*calendar.CalendarListEntry, query eventQuery, concurrency int) ([]*Event, error) {
+	events, err := fetchEvents(srv, calendars, query, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	events = inRange(events, query.window)
+	sortEvents(events)
+	return query.truncate(dedupeEvents(events)), nil
+}
+
+// fetchEvents lists the events matching query for every calendar, running at most concurrency requests at a time.
+// The results are in the same order as calendars.  The first error cancels any requests still in flight.
+func fetchEvents(srv *calendar.Service, calendars []*calendar.CalendarListEntry, query eventQuery, concurrency int) ([]*Event, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make([][]*Event, len(calendars))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for worker := 0; worker < concurrency && worker < len(calendars); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				events, err := fetchCalendarEvents(ctx, srv, calendars[index], query)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+
+				results[index] = events
+			}
+		}()
+	}
+
+feed:
+	for index := range calendars {
+		select {
+		case jobs <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	events := []*Event{}
+	for _, calendarEvents := range results {
+		events = append(events, calendarEvents...)
+	}
+
+	return events, nil
+}
+
+func fetchCalendarEvents(ctx context.Context, srv *calendar.Service, item *calendar.CalendarListEntry, query eventQuery) ([]*Event, error) {
+	timeMin := query.window.start
+	if !query.from.IsZero() {
+		timeMin = query.from
+	}
+
+	timeMax := query.window.end
+	if !query.until.IsZero() {
+		timeMax = query.until
+	}
+
+	request := srv.Events.List(item.Id).
+		TimeMin(timeMin.Format(time.RFC3339)).
+		SingleEvents(query.expandRecurring).
+		Context(ctx)
+	if !timeMax.IsZero() {
+		request.TimeMax(timeMax.Format(time.RFC3339))
+	}
+
+	// Ordered results let paging stop as soon as this calendar has enough events
+	if query.limit > 0 {
+		request.OrderBy("startTime").MaxResults(int64(query.limit))
+	}
+
+	events := []*Event{}
+	for {
+		resp, err := request.Do()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to check calendar. %v", err)
+		}
+
+		for _, event := range resp.Items {
+			parsed, err := newEvent(item, event, query.window.start.Location())
+			if err != nil {
+				return nil, err
+			}
+
+			events = append(events, parsed)
+		}
+		if resp.NextPageToken == "" || (query.limit > 0 && len(events) >= query.limit) {
+			return events, nil
+		}
+
+		request.PageToken(resp.NextPageToken)
+	}
+}
@@ -0,0 +1,125 @@
+package command_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckConcurrency(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	calendars := []*calendar.CalendarListEntry{}
+	events := map[string][]*calendar.Event{}
+	for index := 0; index < 6; index++ {
+		id := fmt.Sprintf("calendar%d", index)
+		calendars = append(calendars, &calendar.CalendarListEntry{Id: id, Selected: true, Primary: index == 0, TimeZone: "UTC"})
+		events[id] = []*calendar.Event{{Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, Summary: fmt.Sprintf("Event %d", index)}}
+	}
+
+	api := getMockCalendarAPI(t, calendars, events)
+	defer api.Close()
+	var inFlight, maxInFlight int32
+	api.eventsHook = func(calendarID string, w http.ResponseWriter, r *http.Request) bool {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		return false
+	}
+
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("concurrency", "2"))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	assert.Equal(
+		t,
+		strings.Join(
+			[]string{
+				"Wed, 9:00AM  calendar0  Event 0",
+				"Wed, 9:00AM  calendar1  Event 1",
+				"Wed, 9:00AM  calendar2  Event 2",
+				"Wed, 9:00AM  calendar3  Event 3",
+				"Wed, 9:00AM  calendar4  Event 4",
+				"Wed, 9:00AM  calendar5  Event 5",
+				"",
+			},
+			"\n",
+		),
+		writer.String(),
+	)
+}
+
+func TestCmdCheckConcurrentFailureCancelsRequests(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	calendars := []*calendar.CalendarListEntry{
+		{Id: "slow1", Selected: true, Primary: true, TimeZone: "UTC"},
+		{Id: "broken", Selected: true},
+		{Id: "slow2", Selected: true},
+	}
+	api := getMockCalendarAPI(t, calendars, nil)
+	defer api.Close()
+	var canceled int32
+	api.eventsHook = func(calendarID string, w http.ResponseWriter, r *http.Request) bool {
+		if calendarID == "broken" {
+			w.WriteHeader(500)
+			return true
+		}
+
+		select {
+		case <-r.Context().Done():
+			atomic.AddInt32(&canceled, 1)
+		case <-time.After(5 * time.Second):
+		}
+
+		return true
+	}
+
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("concurrency", "3"))
+	start := time.Now()
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Unable to check calendar. googleapi: got HTTP response code 500 with body: ",
+	)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	api.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&canceled))
+}
+
+func TestCmdCheckInvalidConcurrency(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("concurrency", "0"))
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), "--concurrency must be at least 1")
+	assert.Equal(t, []error(nil), cb.Errors)
+}
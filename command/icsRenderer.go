@@ -0,0 +1,242 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsDateFormat     = "20060102"
+	icsDateTimeFormat = "20060102T150405"
+	icsLineLength     = 75
+	// icsRecurringYears is how far past the export the VTIMEZONE of a recurring event covers
+	icsRecurringYears = 5
+)
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+var icsPartStats = map[string]string{
+	"needsAction": "NEEDS-ACTION",
+	"accepted":    "ACCEPTED",
+	"declined":    "DECLINED",
+	"tentative":   "TENTATIVE",
+}
+
+// icsRenderer writes events as an RFC 5545 iCalendar file
+type icsRenderer struct {
+	loc *time.Location
+}
+
+// icsWriter writes folded content lines, remembering the first error
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (r icsRenderer) render(w io.Writer, events []*Event) error {
+	ics := &icsWriter{w: w}
+	ics.line("BEGIN:VCALENDAR")
+	ics.line("VERSION:2.0")
+	ics.line(fmt.Sprintf("PRODID:-//guywithnose//%s %s//EN", Name, Version))
+	ics.line("CALSCALE:GREGORIAN")
+	for _, zone := range r.timeZones(events) {
+		zone.render(ics)
+	}
+
+	stamp := Now().UTC().Format(icsDateTimeFormat) + "Z"
+	for _, event := range events {
+		r.renderEvent(ics, event, stamp)
+	}
+
+	ics.line("END:VCALENDAR")
+	return ics.err
+}
+
+func (r icsRenderer) renderEvent(ics *icsWriter, event *Event, stamp string) {
+	ics.line("BEGIN:VEVENT")
+	ics.line("UID:" + icsUID(event))
+	ics.line("DTSTAMP:" + stamp)
+	if event.AllDay {
+		end := event.End
+		if !end.After(event.Start) {
+			end = event.Start.AddDate(0, 0, 1)
+		}
+
+		ics.line("DTSTART;VALUE=DATE:" + event.Start.Format(icsDateFormat))
+		ics.line("DTEND;VALUE=DATE:" + end.Format(icsDateFormat))
+	} else {
+		ics.line("DTSTART" + r.dateTime(event, event.Start))
+		ics.line("DTEND" + r.dateTime(event, event.End))
+	}
+
+	if !event.originalStart.IsZero() {
+		if event.AllDay {
+			ics.line("RECURRENCE-ID;VALUE=DATE:" + event.originalStart.Format(icsDateFormat))
+		} else {
+			ics.line("RECURRENCE-ID" + r.dateTime(event, event.originalStart))
+		}
+	}
+
+	ics.text("SUMMARY", event.Summary)
+	ics.text("LOCATION", event.Location)
+	ics.text("DESCRIPTION", event.Description)
+	if event.Status != "" {
+		ics.line("STATUS:" + strings.ToUpper(event.Status))
+	}
+
+	for _, attendee := range event.attendees {
+		if attendee.Email == "" {
+			continue
+		}
+
+		property := "ATTENDEE"
+		if attendee.DisplayName != "" {
+			property += ";CN=" + icsParam(attendee.DisplayName)
+		}
+
+		if partStat, ok := icsPartStats[attendee.ResponseStatus]; ok {
+			property += ";PARTSTAT=" + partStat
+		}
+
+		if attendee.Optional {
+			property += ";ROLE=OPT-PARTICIPANT"
+		}
+
+		ics.line(property + ":mailto:" + attendee.Email)
+	}
+
+	for _, rule := range event.Recurrence {
+		ics.line(rule)
+	}
+
+	ics.line("END:VEVENT")
+}
+
+// dateTime formats the parameters and value of a DTSTART style property in the event's timezone
+func (r icsRenderer) dateTime(event *Event, t time.Time) string {
+	name, loc := r.timeZone(event)
+	if loc == nil {
+		return ":" + t.UTC().Format(icsDateTimeFormat) + "Z"
+	}
+
+	return fmt.Sprintf(";TZID=%s:%s", name, t.In(loc).Format(icsDateTimeFormat))
+}
+
+// timeZone returns the TZID of a timed event's times, or a nil location when they are written in UTC
+func (r icsRenderer) timeZone(event *Event) (string, *time.Location) {
+	name := event.timeZone
+	if name == "" {
+		name = r.loc.String()
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "UTC" || name == "Local" {
+		return "", nil
+	}
+
+	return name, loc
+}
+
+// timeZones returns a VTIMEZONE for each TZID used by events, covering the times of the events that use it
+func (r icsRenderer) timeZones(events []*Event) []*icsTimeZone {
+	zones := map[string]*icsTimeZone{}
+	for _, event := range events {
+		if event.AllDay {
+			continue
+		}
+
+		name, loc := r.timeZone(event)
+		if loc == nil {
+			continue
+		}
+
+		zone, ok := zones[name]
+		if !ok {
+			zone = &icsTimeZone{name: name, loc: loc, from: event.Start, to: event.End}
+			zones[name] = zone
+		}
+
+		times := []time.Time{event.Start, event.End, event.originalStart}
+		if len(event.Recurrence) > 0 {
+			// A series keeps repeating after the export so its zone covers the next few years too
+			times = append(times, Now().AddDate(icsRecurringYears, 0, 0))
+		}
+
+		for _, t := range times {
+			if t.IsZero() {
+				continue
+			}
+
+			if t.Before(zone.from) {
+				zone.from = t
+			}
+
+			if t.After(zone.to) {
+				zone.to = t
+			}
+		}
+	}
+
+	sorted := make([]*icsTimeZone, 0, len(zones))
+	for _, zone := range zones {
+		sorted = append(sorted, zone)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+func icsUID(event *Event) string {
+	if event.ICalUID != "" {
+		return event.ICalUID
+	}
+
+	return event.ID + "@google.com"
+}
+
+// icsParam quotes a parameter value if it contains characters that are special in parameters
+func icsParam(value string) string {
+	value = strings.Replace(value, `"`, "", -1)
+	if strings.ContainsAny(value, ":;,") {
+		return `"` + value + `"`
+	}
+
+	return value
+}
+
+// text writes a TEXT property, skipping it when value is empty
+func (ics *icsWriter) text(name, value string) {
+	if value != "" {
+		ics.line(name + ":" + icsTextEscaper.Replace(value))
+	}
+}
+
+// line writes a content line, folding it so no line is longer than 75 octets
+func (ics *icsWriter) line(content string) {
+	if ics.err != nil {
+		return
+	}
+
+	limit := icsLineLength
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+
+		_, ics.err = fmt.Fprintf(ics.w, "%s\r\n ", content[:cut])
+		if ics.err != nil {
+			return
+		}
+
+		content = content[cut:]
+		// The leading space of a continuation line counts toward its length
+		limit = icsLineLength - 1
+	}
+
+	_, ics.err = fmt.Fprintf(ics.w, "%s\r\n", content)
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"fmt"
+	"time"
+)
+
+// icsTimeZoneEpoch starts the observance of zones that have no transitions near the exported events
+const icsTimeZoneEpoch = "19700101T000000"
+
+// icsTimeZone is the VTIMEZONE component for a TZID used from from until to
+type icsTimeZone struct {
+	name string
+	loc  *time.Location
+	from time.Time
+	to   time.Time
+}
+
+// zoneTransition is a change of a zone's UTC offset
+type zoneTransition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+// render writes the zone with an observance for each transition from the year before from until to, so every
+// exported time is covered by the observance that started before it
+func (zone *icsTimeZone) render(ics *icsWriter) {
+	ics.line("BEGIN:VTIMEZONE")
+	ics.line("TZID:" + zone.name)
+	transitions := zoneTransitions(zone.loc, zone.from.AddDate(-1, 0, 0), zone.to)
+	if len(transitions) == 0 || transitions[0].at.After(zone.from) {
+		name, offset := zone.from.In(zone.loc).Zone()
+		observance := "STANDARD"
+		if zone.from.In(zone.loc).IsDST() {
+			observance = "DAYLIGHT"
+		}
+
+		ics.line("BEGIN:" + observance)
+		ics.line("DTSTART:" + icsTimeZoneEpoch)
+		ics.line("TZOFFSETFROM:" + icsOffset(offset))
+		ics.line("TZOFFSETTO:" + icsOffset(offset))
+		ics.line("TZNAME:" + name)
+		ics.line("END:" + observance)
+	}
+
+	for _, transition := range transitions {
+		observance := "STANDARD"
+		if transition.dst {
+			observance = "DAYLIGHT"
+		}
+
+		// The start of an observance is the local time before the transition
+		start := transition.at.In(time.FixedZone("", transition.offsetFrom))
+		ics.line("BEGIN:" + observance)
+		ics.line("DTSTART:" + start.Format(icsDateTimeFormat))
+		ics.line("TZOFFSETFROM:" + icsOffset(transition.offsetFrom))
+		ics.line("TZOFFSETTO:" + icsOffset(transition.offsetTo))
+		ics.line("TZNAME:" + transition.name)
+		ics.line("END:" + observance)
+	}
+
+	ics.line("END:VTIMEZONE")
+}
+
+// zoneTransitions finds the times between from and to when loc's offset changes.
+// Offsets are compared a day apart and each change is then narrowed down to the second.
+func zoneTransitions(loc *time.Location, from, to time.Time) []zoneTransition {
+	transitions := []zoneTransition{}
+	previous := from.Truncate(time.Second)
+	_, previousOffset := previous.In(loc).Zone()
+	for previous.Before(to) {
+		next := previous.Add(24 * time.Hour)
+		_, nextOffset := next.In(loc).Zone()
+		if nextOffset != previousOffset {
+			before, after := previous, next
+			for after.Sub(before) > time.Second {
+				middle := before.Add(after.Sub(before) / 2).Truncate(time.Second)
+				if _, offset := middle.In(loc).Zone(); offset == previousOffset {
+					before = middle
+				} else {
+					after = middle
+				}
+			}
+
+			name, _ := after.In(loc).Zone()
+			transitions = append(transitions, zoneTransition{
+				at:         after,
+				offsetFrom: previousOffset,
+				offsetTo:   nextOffset,
+				name:       name,
+				dst:        after.In(loc).IsDST(),
+			})
+		}
+
+		previous, previousOffset = next, nextOffset
+	}
+
+	return transitions
+}
+
+// icsOffset formats a UTC offset in seconds like -0500
+func icsOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	formatted := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		formatted += fmt.Sprintf("%02d", offset%60)
+	}
+
+	return formatted
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonEvent is the schema written by --output json and jsonl.  Fields are never omitted so the format stays stable.
+type jsonEvent struct {
+	CalendarID     string `json:"calendarId"`
+	EventID        string `json:"eventId"`
+	Summary        string `json:"summary"`
+	Start          string `json:"start"`
+	End            string `json:"end"`
+	AllDay         bool   `json:"allDay"`
+	Location       string `json:"location"`
+	Status         string `json:"status"`
+	ResponseStatus string `json:"responseStatus"`
+	ConferenceLink string `json:"conferenceLink"`
+	Profile        string `json:"profile"`
+}
+
+// jsonRenderer writes events as a JSON array or, when lines is set, one JSON object per line
+type jsonRenderer struct {
+	loc   *time.Location
+	lines bool
+}
+
+func (r jsonRenderer) render(w io.Writer, events []*Event) error {
+	jsonEvents := make([]jsonEvent, 0, len(events))
+	for _, event := range events {
+		jsonEvents = append(jsonEvents, r.newJSONEvent(event))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if !r.lines {
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jsonEvents)
+	}
+
+	for _, event := range jsonEvents {
+		err := encoder.Encode(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r jsonRenderer) newJSONEvent(event *Event) jsonEvent {
+	return jsonEvent{
+		CalendarID:     event.CalendarID,
+		EventID:        event.ID,
+		Summary:        event.Summary,
+		Start:          event.Start.In(r.loc).Format(time.RFC3339),
+		End:            event.End.In(r.loc).Format(time.RFC3339),
+		AllDay:         event.AllDay,
+		Location:       event.Location,
+		Status:         event.Status,
+		ResponseStatus: event.ResponseStatus,
+		ConferenceLink: event.ConferenceLink,
+		Profile:        event.Profile,
+	}
+}
@@ -0,0 +1,100 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckJSONOutput(t *testing.T) {
+	for _, format := range []string{"json", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T13:00:00Z")()
+			api := getMockCalendarAPI(t, getJSONTestCalendars(), getJSONTestEvents())
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			assert.Nil(t, set.Set("output", format))
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assertGolden(t, "check."+format, writer.String())
+		})
+	}
+}
+
+func TestCmdCheckJSONOutputEmpty(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("output", "json"))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "[]\n", writer.String())
+}
+
+func TestCmdCheckInvalidOutput(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("output", "xml"))
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), `Invalid output format "xml", expected one of text, json, jsonl`)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
+
+func getJSONTestCalendars() []*calendar.CalendarListEntry {
+	return []*calendar.CalendarListEntry{
+		{Id: "me@example.com", Summary: "Me", Primary: true, Selected: true, TimeZone: "America/New_York"},
+		{Id: "team@example.com", Summary: "Team", Selected: true},
+	}
+}
+
+func getJSONTestEvents() map[string][]*calendar.Event {
+	return map[string][]*calendar.Event{
+		"me@example.com": {
+			{
+				Id:          "standup",
+				Summary:     "Standup",
+				Start:       &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00-05:00"},
+				End:         &calendar.EventDateTime{DateTime: "2017-11-08T09:15:00-05:00"},
+				Location:    "Room 1 & 2",
+				Status:      "confirmed",
+				HangoutLink: "https://meet.google.com/abc-defg-hij",
+				Attendees: []*calendar.EventAttendee{
+					{Email: "boss@example.com", ResponseStatus: "accepted"},
+					{Email: "me@example.com", Self: true, ResponseStatus: "tentative"},
+				},
+			},
+		},
+		"team@example.com": {
+			{
+				Id:      "offsite",
+				Summary: "Offsite",
+				Start:   &calendar.EventDateTime{Date: "2017-11-08"},
+				End:     &calendar.EventDateTime{Date: "2017-11-09"},
+				Status:  "confirmed",
+			},
+			{
+				Id:      "dinner",
+				Summary: "Team dinner",
+				Start:   &calendar.EventDateTime{DateTime: "2017-11-08T23:30:00Z"},
+				End:     &calendar.EventDateTime{DateTime: "2017-11-09T01:30:00Z"},
+				Status:  "tentative",
+			},
+		},
+	}
+}
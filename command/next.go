@@ -0,0 +1,94 @@
+package command
+
+import (
+	"time"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// DefaultNextCount is the number of events next shows without --count or --within
+const DefaultNextCount = 5
+
+// CmdNext lists the next upcoming events with a header for each day
+func CmdNext(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker next\"", 1)
+		}
+
+		options, err := parseCalendarFlags(c)
+		if err != nil {
+			return err
+		}
+
+		count, within, err := parseNextFlags(c)
+		if err != nil {
+			return err
+		}
+
+		options.query = func(loc *time.Location) (eventQuery, error) {
+			return nextQuery(Now().In(loc), count, within), nil
+		}
+
+		output, err := parseOutputFlags(c)
+		if err != nil {
+			return err
+		}
+
+		agenda, err := loadAgenda(c, cmdBuilder, options)
+		if err != nil {
+			return err
+		}
+
+		// Without --within the last day shown is the day of the last event
+		if agenda.window.end.IsZero() {
+			last := agenda.window.start
+			if len(agenda.events) > 0 {
+				last = agenda.events[len(agenda.events)-1].Start.In(last.Location())
+			}
+
+			agenda.window.end = startOfDay(last).AddDate(0, 0, 1)
+		}
+
+		output.dayHeaders = true
+		return newRenderer(output, agenda.window, len(agenda.calendars) > 1, len(agenda.profiles) > 1).render(c.App.Writer, agenda.events)
+	}
+}
+
+// parseNextFlags validates --count and --within.  Only --within lists every event before it, neither lists the next 5.
+func parseNextFlags(c *cli.Context) (int, time.Duration, error) {
+	if c.IsSet("within") && c.Duration("within") <= 0 {
+		return 0, 0, cli.NewExitError("--within must be more than 0", 1)
+	}
+
+	if c.IsSet("count") && c.Int("count") < 1 {
+		return 0, 0, cli.NewExitError("--count must be at least 1", 1)
+	}
+
+	count := DefaultNextCount
+	if c.IsSet("count") {
+		count = c.Int("count")
+	} else if c.IsSet("within") {
+		count = 0
+	}
+
+	return count, c.Duration("within"), nil
+}
+
+// nextQuery lists the events that have not ended by now.  The window starts today so all day events keep their
+// days, and only has an end when within limits how far ahead to look.
+func nextQuery(now time.Time, count int, within time.Duration) eventQuery {
+	query := eventQuery{window: dateRange{start: startOfDay(now)}, from: now, limit: count}
+	if within > 0 {
+		query.until = now.Add(within)
+		query.window.end = startOfDay(query.until).AddDate(0, 0, 1)
+	}
+
+	return query
+}
+
+// startOfDay returns midnight at the start of t's day in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,217 @@
+package command_test
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdNext(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:30:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{
+			{Id: "work", Selected: true, Primary: true, TimeZone: "UTC"},
+			{Id: "home", Selected: true, TimeZone: "UTC"},
+		},
+		map[string][]*calendar.Event{
+			"work": {
+				{Id: "1", Summary: "Standup", Start: &calendar.EventDateTime{DateTime: "2017-11-08T08:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}},
+				{Id: "2", Summary: "Planning", Start: &calendar.EventDateTime{DateTime: "2017-11-09T14:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-09T15:00:00Z"}},
+				{Id: "3", Summary: "Retro", Start: &calendar.EventDateTime{DateTime: "2017-11-13T16:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-13T17:00:00Z"}},
+			},
+			"home": {
+				{Id: "4", Summary: "Holiday", Start: &calendar.EventDateTime{Date: "2017-11-09"}, End: &calendar.EventDateTime{Date: "2017-11-10"}},
+				{Id: "5", Summary: "Dentist", Start: &calendar.EventDateTime{DateTime: "2017-11-09T11:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-09T12:00:00Z"}},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addNextFlags(set)
+	assert.Nil(t, set.Set("count", "4"))
+	assert.Nil(t, command.CmdNext(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		strings.Join(
+			[]string{
+				"Wednesday, November 8",
+				"8:00AM  work  Standup  ◀ now",
+				"",
+				"Thursday, November 9",
+				"All Day  home  Holiday",
+				"11:00AM  home  Dentist",
+				"2:00PM   work  Planning",
+				"",
+			},
+			"\n",
+		),
+		writer.String(),
+	)
+
+	for _, calendarID := range []string{"work", "home"} {
+		query := api.query(calendarID)
+		assert.Equal(t, "startTime", query.Get("orderBy"))
+		assert.Equal(t, "4", query.Get("maxResults"))
+		assert.Equal(t, "true", query.Get("singleEvents"))
+		assert.Equal(t, "2017-11-08T08:30:00Z", query.Get("timeMin"))
+		assert.Equal(t, "", query.Get("timeMax"))
+	}
+}
+
+func TestCmdNextStopsPagingEarly(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	events := []*calendar.Event{}
+	for _, start := range []string{"2017-11-08T09:00:00Z", "2017-11-08T10:00:00Z", "2017-11-08T11:00:00Z", "2017-11-08T12:00:00Z"} {
+		events = append(events, &calendar.Event{Id: start, Summary: "Event", Start: &calendar.EventDateTime{DateTime: start}})
+	}
+
+	api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}}, map[string][]*calendar.Event{"primary": events})
+	defer api.Close()
+	api.pageSize = 2
+	var requests int32
+	api.eventsHook = func(calendarID string, w http.ResponseWriter, r *http.Request) bool {
+		atomic.AddInt32(&requests, 1)
+		return false
+	}
+
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addNextFlags(set)
+	assert.Nil(t, set.Set("count", "2"))
+	assert.Nil(t, command.CmdNext(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, "Wednesday, November 8\n9:00AM   Event\n10:00AM  Event\n", writer.String())
+}
+
+func TestCmdNextWithin(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T20:00:00Z")()
+	events := []*calendar.Event{
+		{Id: "1", Summary: "Trip", Start: &calendar.EventDateTime{Date: "2017-11-09"}, End: &calendar.EventDateTime{Date: "2017-11-13"}},
+	}
+	for _, start := range []string{"2017-11-09T09:00:00Z", "2017-11-09T10:00:00Z", "2017-11-09T11:00:00Z", "2017-11-09T12:00:00Z", "2017-11-09T13:00:00Z", "2017-11-09T14:00:00Z"} {
+		events = append(events, &calendar.Event{Id: start, Summary: "Event", Start: &calendar.EventDateTime{DateTime: start}})
+	}
+
+	api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}}, map[string][]*calendar.Event{"primary": events})
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addNextFlags(set)
+	assert.Nil(t, set.Set("within", "24h"))
+	assert.Nil(t, command.CmdNext(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		strings.Join(
+			[]string{
+				"Thursday, November 9",
+				"Day 1 of 4  Trip",
+				"9:00AM      Event",
+				"10:00AM     Event",
+				"11:00AM     Event",
+				"12:00PM     Event",
+				"1:00PM      Event",
+				"2:00PM      Event",
+				"",
+			},
+			"\n",
+		),
+		writer.String(),
+	)
+
+	query := api.query("primary")
+	assert.Equal(t, "", query.Get("maxResults"))
+	assert.Equal(t, "2017-11-08T20:00:00Z", query.Get("timeMin"))
+	assert.Equal(t, "2017-11-09T20:00:00Z", query.Get("timeMax"))
+}
+
+func TestCmdNextTemplate(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{"primary": {{Id: "1", Summary: "Event", Start: &calendar.EventDateTime{DateTime: "2017-11-10T09:00:00Z"}}}},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addNextFlags(set)
+	assert.Nil(t, set.Set("format", "{{.Start.Format \"Mon 15:04\"}} {{.Summary}}"))
+	assert.Nil(t, command.CmdNext(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Fri 09:00 Event\n", writer.String())
+	assert.Equal(t, "5", api.query("primary").Get("maxResults"))
+}
+
+func TestCmdNextErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		flags map[string]string
+		args  []string
+		err   string
+	}{
+		{"usage", nil, []string{"foo"}, "Usage: \"calChecker next\""},
+		{"count", map[string]string{"count": "0"}, nil, "--count must be at least 1"},
+		{"within", map[string]string{"within": "-1h"}, nil, "--within must be more than 0"},
+		{"output", map[string]string{"output": "xml"}, nil, "Invalid output format \"xml\", expected one of text, json, jsonl"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addNextFlags(set)
+			for name, value := range testCase.flags {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			assert.Nil(t, set.Parse(testCase.args))
+			assert.EqualError(t, command.CmdNext(&runner.Test{})(cli.NewContext(app, set, nil)), testCase.err)
+		})
+	}
+}
+
+func addNextFlags(set *flag.FlagSet) {
+	set.Int("count", 5, "doc")
+	set.Duration("within", 0, "doc")
+	set.String("timezone", "", "doc")
+	set.Var(&cli.StringSlice{}, "calendar", "doc")
+	set.Var(&cli.StringSlice{}, "exclude-calendar", "doc")
+	set.Int("concurrency", 4, "doc")
+	set.String("output", "text", "doc")
+	set.String("format", "", "doc")
+	set.String("template-file", "", "doc")
+	set.Bool("show-end", false, "doc")
+}
+
+func TestCmdNextFlagsBeforeCommand(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	c := getAuthorizedCommandContext(t, testFolder, "next", "--output", "bogus")
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdNext(cb)(c), `Invalid output format "bogus", expected one of text, json, jsonl`)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Stdin is where pasted authorization codes are read from, it allows overriding the input for testing
+var Stdin io.Reader = os.Stdin
+
+// codeFromPaste prints the authorization URL and reads back the code, or the whole URL the browser was
+// redirected to, so the browser can be on a different computer than calChecker.
+func (client Client) codeFromPaste(writer io.Writer, state string, proof pkce) (string, error) {
+	// Nothing listens here, the user copies the code from the address bar of the page that fails to load
+	client.config.RedirectURL = "http://127.0.0.1" + callbackPath
+	fmt.Fprintf(writer, "Open %s in a browser on any computer\n", client.authCodeURL(state, proof))
+	fmt.Fprint(writer, "After you allow access the browser will go to a page on 127.0.0.1 that does not load.\n")
+	fmt.Fprint(writer, "Paste the full URL of that page or its code parameter here: ")
+	line, err := bufio.NewReader(Stdin).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("Unable to read authorization code: %v", err)
+		}
+
+		return "", fmt.Errorf("No authorization code was entered")
+	}
+
+	return parsePastedCode(line, state)
+}
+
+// parsePastedCode accepts either a bare code or the redirected URL, checking the state and error of the latter
+func parsePastedCode(input, state string) (string, error) {
+	if !strings.Contains(input, "?") {
+		return input, nil
+	}
+
+	redirect, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse the pasted URL: %v", err)
+	}
+
+	query := redirect.Query()
+	if query.Get("state") != state {
+		return "", fmt.Errorf("Invalid authorization state, the pasted URL is not from this login")
+	}
+
+	if reason := query.Get("error"); reason != "" {
+		return "", fmt.Errorf("Authorization failed: %s", reason)
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("Authorization failed: no code was received")
+	}
+
+	return code, nil
+}
@@ -0,0 +1,118 @@
+package command_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+// pasteReader plays the part of the user, building what they paste from the authorization URL calChecker printed
+type pasteReader struct {
+	t       *testing.T
+	checker *pkceChecker
+	output  *bytes.Buffer
+	paste   func(authURL *url.URL) string
+	input   io.Reader
+}
+
+func (reader *pasteReader) Read(p []byte) (int, error) {
+	if reader.input == nil {
+		line := strings.SplitN(reader.output.String(), "\n", 2)[0]
+		authURL, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(line, "Open "), " in a browser on any computer"))
+		require.Nil(reader.t, err)
+		assert.Equal(reader.t, "http://127.0.0.1/oauth2callback", authURL.Query().Get("redirect_uri"))
+		reader.checker.recordChallenge(reader.t, &http.Request{Form: authURL.Query()})
+		reader.input = strings.NewReader(reader.paste(authURL))
+	}
+
+	return reader.input.Read(p)
+}
+
+func TestCmdAuthLoginNoBrowser(t *testing.T) {
+	cases := []struct {
+		name     string
+		paste    func(authURL *url.URL) string
+		expected string
+	}{
+		{
+			"redirect URL",
+			func(authURL *url.URL) string {
+				return fmt.Sprintf("%s?state=%s&code=foo&scope=calendar\n", authURL.Query().Get("redirect_uri"), url.QueryEscape(authURL.Query().Get("state")))
+			},
+			"",
+		},
+		{"code", func(*url.URL) string { return "  foo  \n" }, ""},
+		{"code without newline", func(*url.URL) string { return "foo" }, ""},
+		{
+			"wrong state",
+			func(authURL *url.URL) string { return authURL.Query().Get("redirect_uri") + "?state=other&code=foo\n" },
+			"Could not get OAuth token: Invalid authorization state, the pasted URL is not from this login",
+		},
+		{
+			"denied",
+			func(authURL *url.URL) string {
+				return fmt.Sprintf("%s?error=access_denied&state=%s\n", authURL.Query().Get("redirect_uri"), url.QueryEscape(authURL.Query().Get("state")))
+			},
+			"Could not get OAuth token: Authorization failed: access_denied",
+		},
+		{"nothing", func(*url.URL) string { return "" }, "Could not get OAuth token: No authorization code was entered"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			checker := &pkceChecker{}
+			ts := getMockPasteAPI(t, checker)
+			defer ts.Close()
+			app, writer, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+			set.Bool("no-browser", true, "doc")
+			reader := &pasteReader{t: t, checker: checker, output: writer, paste: testCase.paste}
+			command.Stdin = reader
+			defer func() { command.Stdin = os.Stdin }()
+			cb := &runner.Test{}
+			err := command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil))
+			assert.Equal(t, []error(nil), cb.Errors)
+			if testCase.expected != "" {
+				assert.EqualError(t, err, testCase.expected)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(
+				t,
+				[]string{
+					"After you allow access the browser will go to a page on 127.0.0.1 that does not load.",
+					"Paste the full URL of that page or its code parameter here: Authorized, the token was saved to /tmp/testCalChecker/tokenFile",
+					"",
+				},
+				strings.Split(writer.String(), "\n")[1:],
+			)
+			assert.Equal(t, "fakeToken", readTokenFile(t, filepath.Join(testFolder, "tokenFile")).AccessToken)
+		})
+	}
+}
+
+func getMockPasteAPI(t *testing.T, checker *pkceChecker) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "foo", r.FormValue("code"))
+		assert.Equal(t, "http://127.0.0.1/oauth2callback", r.FormValue("redirect_uri"))
+		assert.True(t, checker.verified(r))
+		_, err := w.Write([]byte(url.Values{"access_token": []string{"fakeToken"}}.Encode()))
+		assert.Nil(t, err)
+	}))
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/oauth2"
+)
+
+// pkce holds the RFC 7636 proof key for a single authorization
+type pkce struct {
+	verifier string
+}
+
+func newPKCE() (pkce, error) {
+	verifier, err := randomString()
+	if err != nil {
+		return pkce{}, err
+	}
+
+	return pkce{verifier: verifier}, nil
+}
+
+// challenge is the S256 code challenge for the verifier
+func (proof pkce) challenge() string {
+	sum := sha256.Sum256([]byte(proof.verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authCodeOptions are the parameters that add the challenge to the authorization URL
+func (proof pkce) authCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_challenge", proof.challenge()),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	}
+}
+
+// exchangeContext returns a context for Config.Exchange that sends the code verifier with the token request.
+// The vendored oauth2 package can not add extra parameters to the exchange itself.
+func (proof pkce) exchangeContext(ctx context.Context) context.Context {
+	client := &http.Client{Transport: verifierTransport{verifier: proof.verifier, base: http.DefaultTransport}}
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
+
+// verifierTransport adds the code_verifier to form encoded token requests
+type verifierTransport struct {
+	verifier string
+	base     http.RoundTripper
+}
+
+func (transport verifierTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		return transport.base.RoundTrip(r)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	values.Set("code_verifier", transport.verifier)
+	encoded := values.Encode()
+	request := new(http.Request)
+	*request = *r
+	request.Header = make(http.Header, len(r.Header))
+	for key, value := range r.Header {
+		request.Header[key] = value
+	}
+
+	request.Body = ioutil.NopCloser(bytes.NewBufferString(encoded))
+	request.ContentLength = int64(len(encoded))
+	request.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+	return transport.base.RoundTrip(request)
+}
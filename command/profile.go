@@ -0,0 +1,196 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	// allProfiles selects every profile that has a token file
+	allProfiles = "all"
+	// defaultProfile names the token file given by --tokenFile itself
+	defaultProfile = "default"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// profile is a Google account with its own token file
+type profile struct {
+	name      string
+	tokenFile string
+	// filter replaces the calendar flags when the profile's config chooses its own calendars
+	filter *calendarFilter
+}
+
+// resolveProfiles returns the profiles chosen by --profile.  Without it the token file is used as is.
+func resolveProfiles(c *cli.Context) ([]profile, error) {
+	name := globalString(c, "profile")
+	tokenFile := globalString(c, "tokenFile")
+	if name == "" {
+		return []profile{{tokenFile: tokenFile}}, nil
+	}
+
+	if tokenFile == "" {
+		return nil, cli.NewExitError("--profile needs a tokenFile to name the token file of each profile", 1)
+	}
+
+	if name != allProfiles {
+		if !profileNamePattern.MatchString(name) {
+			return nil, cli.NewExitError(fmt.Sprintf("Invalid profile name %q, use only letters, numbers, - and _", name), 1)
+		}
+
+		selected, err := newProfile(c, name, profileTokenFile(tokenFile, name))
+		return []profile{selected}, err
+	}
+
+	profiles, err := findProfiles(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err = addConfigProfiles(c, profiles, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profiles) == 0 {
+		return nil, cli.NewExitError("No profiles have logged in, run calChecker --profile NAME auth login", 1)
+	}
+
+	return profiles, nil
+}
+
+// newProfile applies the settings of the profile in the config, if it has any
+func newProfile(c *cli.Context, name, tokenFile string) (profile, error) {
+	selected := profile{name: name, tokenFile: tokenFile}
+	profiles, _ := configProfiles(c)
+	settings, ok := profiles[name]
+	if !ok {
+		return selected, nil
+	}
+
+	if settings.TokenFile != "" {
+		selected.tokenFile = expandHome(settings.TokenFile)
+	}
+
+	// Calendars given on the command line or in the environment apply to every profile
+	if len(settings.Calendar)+len(settings.ExcludeCalendar) == 0 || userSet(c, "calendar") || userSet(c, "exclude-calendar") {
+		return selected, nil
+	}
+
+	include, err := newCalendarMatchers(settings.Calendar, "calendar")
+	if err != nil {
+		return selected, err
+	}
+
+	exclude, err := newCalendarMatchers(settings.ExcludeCalendar, "exclude-calendar")
+	if err != nil {
+		return selected, err
+	}
+
+	selected.filter = &calendarFilter{include: include, exclude: exclude}
+	return selected, nil
+}
+
+// addConfigProfiles adds the profiles in the config that were not found by their token files, keeping them sorted.
+// Profiles whose token file does not exist have not logged in and are left out.
+func addConfigProfiles(c *cli.Context, found []profile, tokenFile string) ([]profile, error) {
+	candidates := make([]profile, 0, len(found))
+	seen := map[string]bool{}
+	for _, existing := range found {
+		candidates = append(candidates, existing)
+		seen[existing.name] = true
+	}
+
+	_, names := configProfiles(c)
+	for _, name := range names {
+		if !seen[name] {
+			candidates = append(candidates, profile{name: name, tokenFile: profileTokenFile(tokenFile, name)})
+		}
+	}
+
+	profiles := []profile{}
+	for _, candidate := range candidates {
+		selected, err := newProfile(c, candidate.name, candidate.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := os.Stat(selected.tokenFile); err != nil {
+			continue
+		}
+
+		profiles = append(profiles, selected)
+	}
+
+	sort.SliceStable(profiles, func(i, j int) bool {
+		if profiles[i].name == defaultProfile || profiles[j].name == defaultProfile {
+			return profiles[i].name == defaultProfile && profiles[j].name != defaultProfile
+		}
+
+		return profiles[i].name < profiles[j].name
+	})
+	return profiles, nil
+}
+
+// userSet reports whether a flag was given on the command line or in the environment rather than in the config
+func userSet(c *cli.Context, name string) bool {
+	return (c.IsSet(name) || c.GlobalIsSet(name)) && !fromConfig(c, name)
+}
+
+// resolveProfile returns the profile chosen by --profile for commands that work with a single token
+func resolveProfile(c *cli.Context) (profile, error) {
+	if globalString(c, "profile") == allProfiles {
+		return profile{}, cli.NewExitError("--profile all can not be used here, choose a single profile", 1)
+	}
+
+	profiles, err := resolveProfiles(c)
+	if err != nil {
+		return profile{}, err
+	}
+
+	return profiles[0], nil
+}
+
+// profileTokenFile adds the profile name before the extension of tokenFile, so token.json becomes token.work.json
+func profileTokenFile(tokenFile, name string) string {
+	if name == defaultProfile {
+		return tokenFile
+	}
+
+	ext := filepath.Ext(tokenFile)
+	return strings.TrimSuffix(tokenFile, ext) + "." + name + ext
+}
+
+// findProfiles lists the profiles with a token file next to tokenFile, sorted by name
+func findProfiles(tokenFile string) ([]profile, error) {
+	ext := filepath.Ext(tokenFile)
+	prefix := strings.TrimSuffix(tokenFile, ext) + "."
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find profiles: %v", err)
+	}
+
+	profiles := []profile{}
+	if _, err := os.Stat(tokenFile); err == nil {
+		profiles = append(profiles, profile{name: defaultProfile, tokenFile: tokenFile})
+	}
+
+	for _, match := range matches {
+		// Other names, like those of temporary files, are not profiles
+		name := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if name == defaultProfile || !profileNamePattern.MatchString(name) {
+			continue
+		}
+
+		profiles = append(profiles, profile{name: name, tokenFile: match})
+	}
+
+	return profiles, nil
+}
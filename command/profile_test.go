@@ -0,0 +1,184 @@
+package command_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+	"golang.org/x/oauth2"
+)
+
+func TestCmdCheckAllProfiles(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockProfileAPI(t)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work"), "workToken")
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.home"), "homeToken")
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work.123.tmp"), "partialToken")
+	addCheckFlags(set)
+	set.String("profile", "all", "doc")
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(
+		t,
+		strings.Join(
+			[]string{
+				"Wed, 9:00AM   default  Calendar  fakeToken event",
+				"Wed, 10:00AM  home     Calendar  homeToken event",
+				"Wed, 11:00AM  work     Calendar  workToken event",
+				"",
+			},
+			"\n",
+		),
+		writer.String(),
+	)
+}
+
+func TestCmdCheckAllProfilesJSON(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockProfileAPI(t)
+	defer api.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work"), "workToken")
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.home"), "homeToken")
+	addCheckFlags(set)
+	set.String("profile", "all", "doc")
+	assert.Nil(t, set.Set("output", "jsonl"))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	profiles := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(writer.String()), "\n") {
+		event := struct {
+			Profile string `json:"profile"`
+		}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &event))
+		profiles = append(profiles, event.Profile)
+	}
+
+	assert.Equal(t, []string{"home", "work"}, profiles)
+}
+
+func TestCmdCheckProfile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockProfileAPI(t)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	writeProfileToken(t, filepath.Join(testFolder, "tokenFile.work"), "workToken")
+	addCheckFlags(set)
+	set.String("profile", "work", "doc")
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Wed, 11:00AM  workToken event\n", writer.String())
+}
+
+func TestCmdCheckProfileErrors(t *testing.T) {
+	testCases := []struct {
+		profile   string
+		tokenFile string
+		err       string
+	}{
+		{"../work", "tokenFile", `Invalid profile name "../work", use only letters, numbers, - and _`},
+		{"all", "tokenFile", "No profiles have logged in, run calChecker --profile NAME auth login"},
+		{"work", "", "You must specify a tokenFile"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.profile, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+			addCheckFlags(set)
+			set.String("profile", testCase.profile, "doc")
+			if testCase.tokenFile == "" {
+				assert.Nil(t, set.Set("tokenFile", ""))
+			}
+
+			assert.EqualError(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)), testCase.err)
+		})
+	}
+}
+
+func TestCmdAuthLoginProfile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	set.Bool("device", false, "doc")
+	set.String("profile", "work", "doc")
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	var OAuthURL string
+	ec.Closure = func(command string) {
+		OAuthURL = strings.Replace(command, "xdg-open ", "", -1)
+		assert.Equal(t, http.StatusOK, getStatus(t, OAuthURL))
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Equal(
+		t,
+		fmt.Sprintf("Attempting to open %s in your browser\nAuthorized, the token was saved to /tmp/testCalChecker/tokenFile.work\n", OAuthURL),
+		writer.String(),
+	)
+	assert.Equal(t, "fakeToken", readTokenFile(t, filepath.Join(testFolder, "tokenFile.work")).AccessToken)
+	_, err := os.Stat(filepath.Join(testFolder, "tokenFile"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCmdAuthLoginAllProfiles(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	set.Bool("device", false, "doc")
+	set.String("profile", "all", "doc")
+	assert.EqualError(t, command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)), "--profile all can not be used here, choose a single profile")
+}
+
+// getMockProfileAPI serves one calendar whose only event is named after the access token, so each profile sees
+// different events.  The events start an hour apart in the order default, home, work.
+func getMockProfileAPI(t *testing.T) *mockCalendarAPI {
+	api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Summary: "Calendar", Selected: true, Primary: true, TimeZone: "UTC"}}, nil)
+	hours := map[string]int{"fakeToken": 9, "homeToken": 10, "workToken": 11}
+	api.eventsHook = func(calendarID string, w http.ResponseWriter, r *http.Request) bool {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		hour, ok := hours[token]
+		assert.True(t, ok, token)
+		start := fmt.Sprintf("2017-11-08T%02d:00:00Z", hour)
+		event := &calendar.Event{
+			Id:      token,
+			Summary: token + " event",
+			Start:   &calendar.EventDateTime{DateTime: start},
+			End:     &calendar.EventDateTime{DateTime: start},
+		}
+		assert.Nil(t, json.NewEncoder(w).Encode(calendar.Events{Items: []*calendar.Event{event}}))
+		return true
+	}
+
+	return api
+}
+
+func writeProfileToken(t *testing.T, tokenFile, accessToken string) {
+	token, err := json.Marshal(&oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(tokenFile, token, 0600))
+}
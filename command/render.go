@@ -0,0 +1,233 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var outputFormats = []string{"text", "json", "jsonl"}
+
+// renderer writes a list of events
+type renderer interface {
+	render(w io.Writer, events []*Event) error
+}
+
+// outputOptions are the validated output flags
+type outputOptions struct {
+	format   string
+	template *template.Template
+	showEnd  bool
+	// dayHeaders groups table rows under a header for each day instead of showing the day on every row
+	dayHeaders bool
+}
+
+// parseOutputFlags validates the --output, --format and --template-file flags
+func parseOutputFlags(c *cli.Context) (outputOptions, error) {
+	options := outputOptions{format: c.String("output"), showEnd: c.Bool("show-end")}
+	if !validOutputFormat(options.format) {
+		return options, cli.NewExitError(fmt.Sprintf("Invalid output format %q, expected one of %s", options.format, strings.Join(outputFormats, ", ")), 1)
+	}
+
+	if c.String("format") == "" && c.String("template-file") == "" {
+		return options, nil
+	}
+
+	if c.String("format") != "" && c.String("template-file") != "" {
+		return options, cli.NewExitError("You cannot specify both --format and --template-file", 1)
+	}
+
+	if options.format != "" && options.format != "text" {
+		return options, cli.NewExitError(fmt.Sprintf("You cannot use a template with --output %s", options.format), 1)
+	}
+
+	var err error
+	options.template, err = parseEventTemplate(c.String("format"), c.String("template-file"))
+	return options, err
+}
+
+func validOutputFormat(format string) bool {
+	if format == "" {
+		return true
+	}
+
+	for _, outputFormat := range outputFormats {
+		if format == outputFormat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newRenderer builds the renderer chosen by the output flags
+func newRenderer(options outputOptions, window dateRange, showCalendar, showProfile bool) renderer {
+	if options.template != nil {
+		return templateRenderer{template: options.template}
+	}
+
+	switch options.format {
+	case "json":
+		return jsonRenderer{loc: window.start.Location()}
+	case "jsonl":
+		return jsonRenderer{loc: window.start.Location(), lines: true}
+	default:
+		return tableRenderer{
+			window:       window,
+			showCalendar: showCalendar,
+			showProfile:  showProfile,
+			showEnd:      options.showEnd,
+			dayHeaders:   options.dayHeaders,
+		}
+	}
+}
+
+// tableRenderer writes events as aligned text columns
+type tableRenderer struct {
+	window       dateRange
+	showCalendar bool
+	showProfile  bool
+	showEnd      bool
+	dayHeaders   bool
+}
+
+// tableRow is one line of the table.  Multi-day all day events get a row for each of their days in the range.
+type tableRow struct {
+	event *Event
+	start time.Time
+}
+
+func (r tableRenderer) render(w io.Writer, events []*Event) error {
+	timeFormat := "Mon, 3:04PM"
+	if r.dayHeaders {
+		timeFormat = "3:04PM"
+	} else if r.window.days() > 1 {
+		timeFormat = "Mon Jan 2, 3:04PM"
+	}
+
+	now := Now()
+	tabW := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var day time.Time
+	for _, row := range r.rows(events) {
+		if rowDay := startOfDay(row.start.In(r.window.start.Location())); r.dayHeaders && !rowDay.Equal(day) {
+			if !day.IsZero() {
+				fmt.Fprintln(tabW)
+			}
+
+			fmt.Fprintln(tabW, rowDay.Format("Monday, January 2"))
+			day = rowDay
+		}
+
+		columns := []string{r.when(row, timeFormat)}
+		if r.showEnd {
+			columns = append(columns, r.end(row.event))
+		}
+
+		if r.showProfile {
+			columns = append(columns, row.event.Profile)
+		}
+
+		if r.showCalendar {
+			columns = append(columns, row.event.CalendarLabel)
+		}
+
+		columns = append(columns, row.event.Summary)
+		if marker := r.marker(row.event, now); marker != "" {
+			columns = append(columns, marker)
+		}
+
+		fmt.Fprintln(tabW, strings.Join(columns, "\t"))
+	}
+
+	return tabW.Flush()
+}
+
+// rows clips all day events to the range, splitting them into a row for each day
+func (r tableRenderer) rows(events []*Event) []tableRow {
+	rows := []tableRow{}
+	for _, event := range events {
+		if !event.AllDay {
+			rows = append(rows, tableRow{event: event, start: event.Start})
+			continue
+		}
+
+		day := event.Start
+		if day.Before(r.window.start) {
+			day = r.window.start
+		}
+
+		for ; day.Before(event.End) && day.Before(r.window.end); day = day.AddDate(0, 0, 1) {
+			rows = append(rows, tableRow{event: event, start: day})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].start.Equal(rows[j].start) {
+			return rows[i].start.Before(rows[j].start)
+		}
+
+		return rows[i].event.AllDay && !rows[j].event.AllDay
+	})
+
+	return rows
+}
+
+// when describes when the row starts.  Rows of multi-day all day events show which of the event's days they are.
+func (r tableRenderer) when(row tableRow, timeFormat string) string {
+	if !row.event.AllDay {
+		return row.start.In(r.window.start.Location()).Format(timeFormat)
+	}
+
+	label := "All Day"
+	total := dateRange{start: row.event.Start, end: row.event.End}.days()
+	if total > 1 {
+		day := dateRange{start: row.event.Start, end: row.start}.days() + 1
+		label = fmt.Sprintf("Day %d of %d", day, total)
+	}
+
+	if r.window.days() > 1 && !r.dayHeaders {
+		return row.start.Format("Mon Jan 2, ") + label
+	}
+
+	return label
+}
+
+// end describes when a timed event ends and how long it lasts
+func (r tableRenderer) end(event *Event) string {
+	if event.AllDay {
+		return ""
+	}
+
+	loc := r.window.start.Location()
+	start := event.Start.In(loc)
+	end := event.End.In(loc)
+	endFormat := "3:04PM"
+	if start.YearDay() != end.YearDay() || start.Year() != end.Year() {
+		endFormat = "Mon, 3:04PM"
+	}
+
+	return fmt.Sprintf("%s (%s)", end.Format(endFormat), formatDuration(event.Duration()))
+}
+
+// marker flags events that are happening now or that started before the range
+func (r tableRenderer) marker(event *Event, now time.Time) string {
+	if event.AllDay {
+		return ""
+	}
+
+	if !now.Before(event.Start) && now.Before(event.End) {
+		return "◀ now"
+	}
+
+	if event.Start.Before(r.window.start) {
+		return "(continued)"
+	}
+
+	return ""
+}
@@ -0,0 +1,118 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckTable(t *testing.T) {
+	cases := []struct {
+		name     string
+		now      string
+		args     map[string]string
+		expected string
+	}{
+		{
+			"default",
+			"2017-11-08T08:00:00Z",
+			nil,
+			"Tue, 11:00PM  Deploy  (continued)\nDay 2 of 3    Offsite\nAll Day       Holiday\nWed, 9:00AM   Standup\nWed, 4:00PM   Planning\n",
+		},
+		{
+			"in progress",
+			"2017-11-08T09:05:00Z",
+			nil,
+			"Tue, 11:00PM  Deploy  (continued)\nDay 2 of 3    Offsite\nAll Day       Holiday\nWed, 9:00AM   Standup  ◀ now\nWed, 4:00PM   Planning\n",
+		},
+		{
+			"started before range and in progress",
+			"2017-11-08T00:30:00Z",
+			nil,
+			"Tue, 11:00PM  Deploy  ◀ now\nDay 2 of 3    Offsite\nAll Day       Holiday\nWed, 9:00AM   Standup\nWed, 4:00PM   Planning\n",
+		},
+		{
+			"show end",
+			"2017-11-08T08:00:00Z",
+			map[string]string{"show-end": "true"},
+			"Tue, 11:00PM  Wed, 1:00AM (2h)  Deploy  (continued)\nDay 2 of 3                      Offsite\nAll Day                         Holiday\nWed, 9:00AM   9:15AM (15m)      Standup\nWed, 4:00PM   5:30PM (1h30m)    Planning\n",
+		},
+		{
+			"multiple days",
+			"2017-11-08T08:00:00Z",
+			map[string]string{"days": "2"},
+			strings.Join(
+				[]string{
+					"Tue Nov 7, 11:00PM     Deploy  (continued)",
+					"Wed Nov 8, Day 2 of 3  Offsite",
+					"Wed Nov 8, All Day     Holiday",
+					"Wed Nov 8, 9:00AM      Standup",
+					"Wed Nov 8, 4:00PM      Planning",
+					"Thu Nov 9, Day 3 of 3  Offsite",
+					"",
+				},
+				"\n",
+			),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, testCase.now)()
+			api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "UTC"}}, getTableTestEvents())
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			assert.Nil(t, set.Set("date", "2017-11-08"))
+			for name, value := range testCase.args {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected, writer.String())
+		})
+	}
+}
+
+func getTableTestEvents() map[string][]*calendar.Event {
+	return map[string][]*calendar.Event{
+		"primary": {
+			{
+				Summary: "Deploy",
+				Start:   &calendar.EventDateTime{DateTime: "2017-11-07T23:00:00Z"},
+				End:     &calendar.EventDateTime{DateTime: "2017-11-08T01:00:00Z"},
+			},
+			{
+				Summary: "Offsite",
+				Start:   &calendar.EventDateTime{Date: "2017-11-07"},
+				End:     &calendar.EventDateTime{Date: "2017-11-10"},
+			},
+			{
+				Summary: "Holiday",
+				Start:   &calendar.EventDateTime{Date: "2017-11-08"},
+				End:     &calendar.EventDateTime{Date: "2017-11-09"},
+			},
+			{
+				Summary: "Standup",
+				Start:   &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"},
+				End:     &calendar.EventDateTime{DateTime: "2017-11-08T09:15:00Z"},
+			},
+			{
+				Summary: "Planning",
+				Start:   &calendar.EventDateTime{DateTime: "2017-11-08T16:00:00Z"},
+				End:     &calendar.EventDateTime{DateTime: "2017-11-08T17:30:00Z"},
+			},
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const serviceAccountType = "service_account"
+
+// credentialType returns the type field of a Google credential file, which is empty for installed app credentials
+func credentialType(credentials []byte) string {
+	var file struct {
+		Type string `json:"type"`
+	}
+
+	_ = json.Unmarshal(credentials, &file)
+	return file.Type
+}
+
+func isServiceAccountFile(credentialFile string) bool {
+	credentials, err := ioutil.ReadFile(credentialFile)
+	return err == nil && credentialType(credentials) == serviceAccountType
+}
+
+// serviceAccountClient authorizes as the service account, or as the --impersonate user through domain-wide delegation
+func (client Client) serviceAccountClient(config *jwt.Config) *http.Client {
+	delegated := *config
+	delegated.Subject = client.options.impersonate
+	return delegated.Client(context.Background())
+}
+
+// defaultCredentialsClient authorizes with Application Default Credentials, such as the key file named by
+// GOOGLE_APPLICATION_CREDENTIALS, gcloud's credentials or the metadata server
+func (client Client) defaultCredentialsClient() (*http.Client, error) {
+	ctx := context.Background()
+	credentials, err := google.FindDefaultCredentials(ctx, calendar.CalendarReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find application default credentials: %v", err)
+	}
+
+	if client.options.impersonate == "" {
+		return oauth2.NewClient(ctx, credentials.TokenSource), nil
+	}
+
+	if credentialType(credentials.JSON) != serviceAccountType {
+		return nil, fmt.Errorf("--impersonate requires service account credentials")
+	}
+
+	config, err := google.JWTConfigFromJSON(credentials.JSON, calendar.CalendarReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse service account credentials: %v", err)
+	}
+
+	return client.serviceAccountClient(config), nil
+}
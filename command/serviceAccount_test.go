@@ -0,0 +1,204 @@
+package command_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+// mockJWTTokenAPI is a stand in for Google's token endpoint that records the claims of each JWT assertion
+type mockJWTTokenAPI struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	claims []map[string]interface{}
+}
+
+func getMockJWTTokenAPI(t *testing.T) *mockJWTTokenAPI {
+	api := &mockJWTTokenAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.FormValue("grant_type"))
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		require.Len(t, parts, 3)
+		decoded, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.Nil(t, err)
+		claims := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(decoded, &claims))
+		api.mutex.Lock()
+		api.claims = append(api.claims, claims)
+		api.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write([]byte(`{"access_token":"serviceToken","token_type":"Bearer","expires_in":3600}`))
+		assert.Nil(t, err)
+	}))
+	return api
+}
+
+func TestCmdCheckServiceAccount(t *testing.T) {
+	cases := []struct {
+		name        string
+		impersonate string
+		adc         bool
+	}{
+		{"key file", "", false},
+		{"impersonate", "me@example.com", false},
+		{"application default credentials", "", true},
+		{"application default credentials impersonate", "me@example.com", true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T08:00:00Z")()
+			tokenAPI := getMockJWTTokenAPI(t)
+			defer tokenAPI.Close()
+			api := getMockCalendarAPI(t, getTestCalendars(), getTestCalendarEvents())
+			defer api.Close()
+			app, writer, set := getServiceAccountAppAndFlagSet(t, testFolder, tokenAPI.URL)
+			addCheckFlags(set)
+			assert.Nil(t, set.Set("impersonate", testCase.impersonate))
+			if testCase.adc {
+				defer setEnv(t, "GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(testFolder, "credFile"))()
+				assert.Nil(t, set.Set("credentialFile", ""))
+				assert.Nil(t, set.Set("adc", "true"))
+			}
+
+			cb := &runner.Test{}
+			assert.Nil(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)))
+			assert.Equal(t, []error(nil), cb.Errors)
+			assert.Equal(t, "All Day      Team  Offsite\nWed, 9:00AM  Me    Standup\nWed, 1:00PM  Team  Planning\n", writer.String())
+			require.Len(t, tokenAPI.claims, 1)
+			assert.Equal(t, "calchecker@project.iam.gserviceaccount.com", tokenAPI.claims[0]["iss"])
+			assert.Equal(t, calendar.CalendarReadonlyScope, tokenAPI.claims[0]["scope"])
+			if testCase.impersonate == "" {
+				assert.NotContains(t, tokenAPI.claims[0], "sub")
+			} else {
+				assert.Equal(t, testCase.impersonate, tokenAPI.claims[0]["sub"])
+			}
+
+			files, err := ioutil.ReadDir(testFolder)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(files))
+		})
+	}
+}
+
+func TestCmdCheckImpersonateRequiresServiceAccount(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	set.String("impersonate", "me@example.com", "doc")
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), "--impersonate requires service account credentials")
+	assert.Equal(t, []error(nil), cb.Errors)
+}
+
+func TestCmdCheckImpersonateApplicationDefaultUser(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	userCredentials := filepath.Join(testFolder, "application_default_credentials.json")
+	assert.Nil(t, ioutil.WriteFile(userCredentials, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"refresh"}`), 0600))
+	defer setEnv(t, "GOOGLE_APPLICATION_CREDENTIALS", userCredentials)()
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	set.Bool("adc", true, "doc")
+	set.String("impersonate", "me@example.com", "doc")
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Could not get OAuth token: --impersonate requires service account credentials",
+	)
+}
+
+func TestCmdCheckMissingApplicationDefaultCredentials(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, "GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(testFolder, "missing.json"))()
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	set.Bool("adc", true, "doc")
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Could not get OAuth token: Unable to find application default credentials: google: error getting credentials using "+
+			"GOOGLE_APPLICATION_CREDENTIALS environment variable: open /tmp/testCalChecker/missing.json: no such file or directory",
+	)
+}
+
+func TestCmdAuthLoginServiceAccount(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getServiceAccountAppAndFlagSet(t, testFolder, "")
+	set.Bool("device", false, "doc")
+	assert.EqualError(
+		t,
+		command.CmdAuthLogin(&runner.Test{})(cli.NewContext(app, set, nil)),
+		"Could not get OAuth token: Service accounts and application default credentials do not need to log in",
+	)
+}
+
+// getServiceAccountAppAndFlagSet sets up a service account key as the credential file, without a token file
+func getServiceAccountAppAndFlagSet(t *testing.T, testFolder, tokenURL string) (*cli.App, *bytes.Buffer, *flag.FlagSet) {
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(testFolder, "credFile"), getServiceAccountKey(t, tokenURL), 0600))
+	assert.Nil(t, set.Set("tokenFile", ""))
+	set.String("impersonate", "", "doc")
+	set.Bool("adc", false, "doc")
+	return app, writer, set
+}
+
+func getServiceAccountKey(t *testing.T, tokenURL string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "keyid",
+		"private_key":    string(privateKey),
+		"client_email":   "calchecker@project.iam.gserviceaccount.com",
+		"client_id":      "id",
+		"token_uri":      tokenURL,
+	})
+	return credentials
+}
+
+func setEnv(t *testing.T, name, value string) func() {
+	previous, wasSet := os.LookupEnv(name)
+	assert.Nil(t, os.Setenv(name, value))
+	return func() {
+		if wasSet {
+			assert.Nil(t, os.Setenv(name, previous))
+			return
+		}
+
+		assert.Nil(t, os.Unsetenv(name))
+	}
+}
@@ -0,0 +1,273 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
+)
+
+// CmdStatus prints a single line with the current and next events for shell prompts and status bars, or the JSON
+// read by waybar and i3bar
+func CmdStatus(cmdBuilder runner.Builder) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 0 {
+			return cli.NewExitError("Usage: \"calChecker status\"", 1)
+		}
+
+		options, err := parseCalendarFlags(c)
+		if err != nil {
+			return err
+		}
+
+		if c.Int("max-length") < 0 {
+			return cli.NewExitError("--max-length can not be negative", 1)
+		}
+
+		err = validStatusOutput(c.String("output"))
+		if err != nil {
+			return err
+		}
+
+		if c.Duration("cache-ttl") < 0 {
+			return cli.NewExitError("--cache-ttl can not be negative", 1)
+		}
+
+		if c.Duration("interval") <= 0 {
+			return cli.NewExitError("--interval must be positive", 1)
+		}
+
+		options.query = func(loc *time.Location) (eventQuery, error) {
+			return statusQuery(Now().In(loc)), nil
+		}
+
+		// A prompt or status bar runs this over and over, so it must not wait for a browser
+		options.nonInteractive = !c.Bool("interactive")
+
+		load := func() (*statusAgenda, error) {
+			return loadStatusAgenda(c, cmdBuilder, options, c.Duration("cache-ttl"))
+		}
+
+		if c.String("output") == "i3bar" {
+			return streamI3bar(c.App.Writer, c.App.ErrWriter, load, c.Duration("interval"), c.Int("max-length"))
+		}
+
+		cached, err := load()
+		if err != nil {
+			return err
+		}
+
+		return renderStatus(c.App.Writer, c.String("output"), cached, Now(), c.Int("max-length"))
+	}
+}
+
+// statusQuery loads today and tomorrow so the next event can be shown late in the day
+func statusQuery(now time.Time) eventQuery {
+	today := startOfDay(now)
+	return eventQuery{window: dateRange{start: today, end: today.AddDate(0, 0, 2)}}
+}
+
+// statusAgenda is the agenda the status is built from.  It is saved to the status cache between runs.
+type statusAgenda struct {
+	Fetched      time.Time `json:"fetched"`
+	Timezone     string    `json:"timezone"`
+	ShowCalendar bool      `json:"showCalendar"`
+	ShowProfile  bool      `json:"showProfile"`
+	Events       []*Event  `json:"events"`
+	loc          *time.Location
+}
+
+// loadStatusAgenda returns the cached agenda when it was fetched less than ttl ago, otherwise it loads and caches it
+func loadStatusAgenda(c *cli.Context, cmdBuilder runner.Builder, options agendaOptions, ttl time.Duration) (*statusAgenda, error) {
+	cacheFile := statusCacheFile(c, options)
+	if ttl > 0 {
+		if cached := readStatusCache(cacheFile, ttl); cached != nil {
+			return cached, nil
+		}
+	}
+
+	agenda, err := loadAgenda(c, cmdBuilder, options)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := agenda.window.start.Location()
+	loaded := &statusAgenda{
+		Fetched:      Now(),
+		Timezone:     loc.String(),
+		ShowCalendar: len(agenda.calendars) > 1,
+		ShowProfile:  len(agenda.profiles) > 1,
+		Events:       agenda.events,
+		loc:          loc,
+	}
+	if ttl > 0 {
+		err = writeStatusCache(cacheFile, loaded)
+		if err != nil {
+			fmt.Fprintf(c.App.ErrWriter, "Warning: unable to write the status cache: %v\n", err)
+		}
+	}
+
+	return loaded, nil
+}
+
+// statusCacheFile names the cache file for the accounts, calendars and timezone chosen by options
+func statusCacheFile(c *cli.Context, options agendaOptions) string {
+	key := []string{globalString(c, "credentialFile"), globalString(c, "impersonate"), c.String("timezone"), options.filter.key()}
+	for _, selected := range options.profiles {
+		key = append(key, selected.name, selected.tokenFile)
+		if selected.filter != nil {
+			key = append(key, selected.filter.key())
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(xdgDir("XDG_CACHE_HOME", ".cache"), Name, fmt.Sprintf("status-%x.json", sum[:8]))
+}
+
+// key describes the filter's patterns
+func (filter calendarFilter) key() string {
+	patterns := []string{}
+	for _, matcher := range filter.include {
+		patterns = append(patterns, "+"+matcher.id)
+	}
+
+	for _, matcher := range filter.exclude {
+		patterns = append(patterns, "-"+matcher.id)
+	}
+
+	return strings.Join(patterns, "\x00")
+}
+
+// readStatusCache returns the cached agenda, or nil when it is missing, unreadable or older than ttl
+func readStatusCache(cacheFile string, ttl time.Duration) *statusAgenda {
+	contents, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return nil
+	}
+
+	cached := &statusAgenda{}
+	err = json.Unmarshal(contents, cached)
+	if err != nil {
+		return nil
+	}
+
+	age := Now().Sub(cached.Fetched)
+	if age < 0 || age >= ttl {
+		return nil
+	}
+
+	cached.loc, err = time.LoadLocation(cached.Timezone)
+	if err != nil {
+		return nil
+	}
+
+	for _, event := range cached.Events {
+		event.Start = event.Start.In(cached.loc)
+		event.End = event.End.In(cached.loc)
+	}
+
+	return cached
+}
+
+func writeStatusCache(cacheFile string, cached *statusAgenda) error {
+	contents, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(cacheFile), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cacheFile, contents, 0600)
+}
+
+// meetingStatus is the event happening now and the one after it.  All day, declined and cancelled events are skipped.
+type meetingStatus struct {
+	current *Event
+	next    *Event
+}
+
+// newMeetingStatus finds the current and next events in events, which must be sorted by start time.
+// When events overlap the one that started last is current.
+func newMeetingStatus(events []*Event, now time.Time) meetingStatus {
+	status := meetingStatus{}
+	for _, event := range events {
+		if event.AllDay || event.ResponseStatus == "declined" || event.Status == "cancelled" {
+			continue
+		}
+
+		if event.Start.After(now) {
+			status.next = event
+			break
+		}
+
+		if now.Before(event.End) {
+			status.current = event
+		}
+	}
+
+	return status
+}
+
+// text describes the status in one line like "▶ Standup (ends 9:15) | next: 1:1 in 42m".  When the line is longer
+// than maxLength the longer summary is shortened first, 0 allows any length.
+func (status meetingStatus) text(loc *time.Location, maxLength int) string {
+	current, next := eventTitle(status.current), eventTitle(status.next)
+	line := status.format(loc, current, next)
+	for maxLength > 0 && utf8.RuneCountInString(line) > maxLength {
+		currentLength, nextLength := utf8.RuneCountInString(current), utf8.RuneCountInString(next)
+		if currentLength <= 1 && nextLength <= 1 {
+			return truncate(maxLength, line)
+		}
+
+		if currentLength >= nextLength {
+			current = truncate(currentLength-1, current)
+		} else {
+			next = truncate(nextLength-1, next)
+		}
+
+		line = status.format(loc, current, next)
+	}
+
+	return line
+}
+
+func (status meetingStatus) format(loc *time.Location, current, next string) string {
+	parts := []string{}
+	if status.current != nil {
+		parts = append(parts, fmt.Sprintf("▶ %s (ends %s)", current, status.current.End.In(loc).Format("3:04")))
+	}
+
+	if status.next != nil {
+		parts = append(parts, fmt.Sprintf("next: %s %s", next, relativeTime(status.next.Start)))
+	}
+
+	if len(parts) == 0 {
+		return "No upcoming events"
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+// eventTitle is the event's summary, or a placeholder for events without one
+func eventTitle(event *Event) string {
+	if event == nil {
+		return ""
+	}
+
+	if event.Summary == "" {
+		return "(No title)"
+	}
+
+	return event.Summary
+}
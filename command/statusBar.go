@@ -0,0 +1,172 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var statusOutputFormats = []string{"text", "waybar", "i3bar"}
+
+// urgentBefore is how soon the next event has to start for the status to be urgent
+const urgentBefore = 5 * time.Minute
+
+// The classes a status bar can style the status with
+const (
+	statusClassIdle   = "idle"
+	statusClassActive = "active"
+	statusClassUrgent = "urgent"
+)
+
+// i3barActiveColor is the text color of the i3bar block during an event.  i3bar shows urgent blocks in its own colors.
+const i3barActiveColor = "#00FF00"
+
+// waybarStatus is the JSON read by a waybar custom module with "return-type": "json"
+type waybarStatus struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip"`
+	Class      string `json:"class"`
+	Percentage int    `json:"percentage"`
+}
+
+// i3barBlock is a block of the i3bar protocol
+type i3barBlock struct {
+	Name     string `json:"name"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Urgent   bool   `json:"urgent"`
+}
+
+// pangoEscaper escapes the characters that waybar would read as Pango markup
+var pangoEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func validStatusOutput(format string) error {
+	for _, statusOutput := range statusOutputFormats {
+		if format == statusOutput {
+			return nil
+		}
+	}
+
+	return cli.NewExitError(fmt.Sprintf("Invalid output format %q, expected one of %s", format, strings.Join(statusOutputFormats, ", ")), 1)
+}
+
+// renderStatus writes the status line as plain text or as the JSON of a waybar custom module
+func renderStatus(w io.Writer, format string, cached *statusAgenda, now time.Time, maxLength int) error {
+	status := newMeetingStatus(cached.Events, now)
+	text := status.text(cached.loc, maxLength)
+	if format != "waybar" {
+		_, err := fmt.Fprintln(w, text)
+		return err
+	}
+
+	tooltip, err := cached.tooltip(now)
+	if err != nil {
+		return err
+	}
+
+	return encodeStatus(w, waybarStatus{
+		Text:       pangoEscaper.Replace(text),
+		Tooltip:    pangoEscaper.Replace(tooltip),
+		Class:      status.class(now),
+		Percentage: status.percentage(now),
+	})
+}
+
+// streamI3bar speaks the i3bar protocol: after the header it writes a status line with one block every interval,
+// loading the agenda again each time so the cache decides when events are fetched.  A failed update keeps the events
+// loaded before it.  It only stops when a status line can not be written, like when i3bar exits.
+func streamI3bar(w, warnings io.Writer, load func() (*statusAgenda, error), interval time.Duration, maxLength int) error {
+	cached, err := load()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(w, "{\"version\":1}\n[\n")
+	if err != nil {
+		return err
+	}
+
+	separator := ""
+	for {
+		now := Now()
+		status := newMeetingStatus(cached.Events, now)
+		block := i3barBlock{Name: Name, FullText: status.text(cached.loc, maxLength), Urgent: status.class(now) == statusClassUrgent}
+		if status.class(now) == statusClassActive {
+			block.Color = i3barActiveColor
+		}
+
+		line := &bytes.Buffer{}
+		err = encodeStatus(line, []i3barBlock{block})
+		if err == nil {
+			_, err = fmt.Fprint(w, separator+line.String())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		separator = ","
+		time.Sleep(interval)
+		reloaded, err := load()
+		if err != nil {
+			fmt.Fprintf(warnings, "Warning: unable to update the events: %v\n", err)
+			continue
+		}
+
+		cached = reloaded
+	}
+}
+
+// encodeStatus writes value as a line of JSON, leaving characters like & as they are
+func encodeStatus(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(value)
+}
+
+// tooltip is the table of today's events
+func (cached *statusAgenda) tooltip(now time.Time) (string, error) {
+	today := startOfDay(now.In(cached.loc))
+	window := dateRange{start: today, end: today.AddDate(0, 0, 1)}
+	events := []*Event{}
+	for _, event := range cached.Events {
+		if event.Start.Before(window.end) && event.End.After(window.start) {
+			events = append(events, event)
+		}
+	}
+
+	if len(events) == 0 {
+		return "No events today", nil
+	}
+
+	buffer := &bytes.Buffer{}
+	err := tableRenderer{window: window, showCalendar: cached.ShowCalendar, showProfile: cached.ShowProfile}.render(buffer, events)
+	return strings.TrimSuffix(buffer.String(), "\n"), err
+}
+
+// class is urgent when the next event starts within urgentBefore, otherwise active during an event and idle between them
+func (status meetingStatus) class(now time.Time) string {
+	if status.next != nil && status.next.Start.Sub(now) < urgentBefore {
+		return statusClassUrgent
+	}
+
+	if status.current != nil {
+		return statusClassActive
+	}
+
+	return statusClassIdle
+}
+
+// percentage is how much of the current event has passed
+func (status meetingStatus) percentage(now time.Time) int {
+	if status.current == nil || status.current.Duration() <= 0 {
+		return 0
+	}
+
+	return int(100 * now.Sub(status.current.Start) / status.current.Duration())
+}
@@ -0,0 +1,131 @@
+package command_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdStatusWaybar(t *testing.T) {
+	testCases := []struct {
+		name     string
+		now      string
+		expected string
+	}{
+		{
+			"active",
+			"2017-11-08T14:15:00Z",
+			`{"text":"▶ Design review (ends 8:50) | next: Q&amp;A in 45m","tooltip":"All Day      Holiday\nWed, 8:00AM  Design review  ◀ now\nWed, 9:00AM  Q&amp;A","class":"active","percentage":30}`,
+		},
+		{
+			"urgent",
+			"2017-11-08T14:56:00Z",
+			`{"text":"next: Q&amp;A in 4m","tooltip":"All Day      Holiday\nWed, 8:00AM  Design review\nWed, 9:00AM  Q&amp;A","class":"urgent","percentage":0}`,
+		},
+		{
+			"idle",
+			"2017-11-08T15:40:00Z",
+			`{"text":"next: Retro in 1d23h20m","tooltip":"All Day      Holiday\nWed, 8:00AM  Design review\nWed, 9:00AM  Q&amp;A","class":"idle","percentage":0}`,
+		},
+		{
+			"tomorrow",
+			"2017-11-09T08:00:00Z",
+			`{"text":"next: Retro in 1d7h","tooltip":"No events today","class":"idle","percentage":0}`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, testCase.now)()
+			api := getMockStatusBarAPI(t)
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addStatusFlags(set)
+			assert.Nil(t, set.Set("cache-ttl", "0"))
+			assert.Nil(t, set.Set("output", "waybar"))
+			assert.Nil(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected+"\n", writer.String())
+		})
+	}
+}
+
+func TestCmdStatusI3bar(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T14:15:00Z")()
+	api := getMockStatusBarAPI(t)
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	// Each status line moves the time on and the third fails like a pipe closed by i3bar
+	writer := &i3barWriter{times: []string{"2017-11-08T14:56:00Z", "2017-11-08T15:40:00Z"}}
+	app.Writer = writer
+	addStatusFlags(set)
+	assert.Nil(t, set.Set("cache-ttl", "0"))
+	assert.Nil(t, set.Set("output", "i3bar"))
+	assert.Nil(t, set.Set("interval", "1ms"))
+	assert.EqualError(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)), "i3bar exited")
+	assert.Equal(
+		t,
+		`{"version":1}
+[
+[{"name":"calChecker","full_text":"▶ Design review (ends 8:50) | next: Q&A in 45m","color":"#00FF00","urgent":false}]
+,[{"name":"calChecker","full_text":"next: Q&A in 4m","urgent":true}]
+`,
+		writer.String(),
+	)
+}
+
+// i3barWriter moves Now to the next of times after each status line and fails once there are no times left
+type i3barWriter struct {
+	bytes.Buffer
+	times []string
+}
+
+func (writer *i3barWriter) Write(p []byte) (int, error) {
+	if !strings.HasSuffix(string(p), "]\n") {
+		return writer.Buffer.Write(p)
+	}
+
+	if len(writer.times) == 0 {
+		return 0, errors.New("i3bar exited")
+	}
+
+	now, err := time.Parse(time.RFC3339, writer.times[0])
+	if err != nil {
+		return 0, err
+	}
+
+	writer.times = writer.times[1:]
+	command.Now = func() time.Time { return now }
+	return writer.Buffer.Write(p)
+}
+
+// getMockStatusBarAPI serves a calendar in America/Chicago with events on 2017-11-08 and 2017-11-10
+func getMockStatusBarAPI(t *testing.T) *mockCalendarAPI {
+	return getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "America/Chicago"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Id: "1", Summary: "Holiday", Start: &calendar.EventDateTime{Date: "2017-11-08"}, End: &calendar.EventDateTime{Date: "2017-11-09"}},
+				{Id: "2", Summary: "Design review", Start: &calendar.EventDateTime{DateTime: "2017-11-08T14:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T14:50:00Z"}},
+				{Id: "3", Summary: "Q&A", Start: &calendar.EventDateTime{DateTime: "2017-11-08T15:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T15:30:00Z"}},
+				{Id: "4", Summary: "Retro", Start: &calendar.EventDateTime{DateTime: "2017-11-10T15:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-10T16:00:00Z"}},
+			},
+		},
+	)
+}
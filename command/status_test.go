@@ -0,0 +1,268 @@
+package command_test
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdStatus(t *testing.T) {
+	standup := &calendar.Event{Id: "1", Summary: "Standup", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T09:15:00Z"}}
+	oneOnOne := &calendar.Event{Id: "2", Summary: "1:1", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:52:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T10:30:00Z"}}
+	testCases := []struct {
+		name      string
+		events    []*calendar.Event
+		maxLength string
+		expected  string
+	}{
+		{"currentAndNext", []*calendar.Event{standup, oneOnOne}, "", "▶ Standup (ends 9:15) | next: 1:1 in 42m\n"},
+		{"currentOnly", []*calendar.Event{standup}, "", "▶ Standup (ends 9:15)\n"},
+		{"nextOnly", []*calendar.Event{oneOnOne}, "", "next: 1:1 in 42m\n"},
+		{"empty", []*calendar.Event{}, "", "No upcoming events\n"},
+		{
+			"skipped",
+			[]*calendar.Event{
+				{Id: "3", Summary: "Holiday", Start: &calendar.EventDateTime{Date: "2017-11-08"}, End: &calendar.EventDateTime{Date: "2017-11-09"}},
+				{
+					Id:        "4",
+					Summary:   "Declined",
+					Start:     &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"},
+					End:       &calendar.EventDateTime{DateTime: "2017-11-08T10:00:00Z"},
+					Attendees: []*calendar.EventAttendee{{Email: "me@example.com", Self: true, ResponseStatus: "declined"}},
+				},
+				{Id: "5", Summary: "Cancelled", Status: "cancelled", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:30:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T10:00:00Z"}},
+				{Id: "6", Start: &calendar.EventDateTime{DateTime: "2017-11-09T08:10:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-09T09:00:00Z"}},
+			},
+			"",
+			"next: (No title) in 23h\n",
+		},
+		{
+			"overlapping",
+			[]*calendar.Event{
+				{Id: "7", Summary: "Offsite", Start: &calendar.EventDateTime{DateTime: "2017-11-08T08:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T12:00:00Z"}},
+				standup,
+			},
+			"",
+			"▶ Standup (ends 9:15)\n",
+		},
+		{
+			"truncated",
+			[]*calendar.Event{
+				{Id: "8", Summary: "Quarterly business review", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T11:00:00Z"}},
+				{Id: "9", Summary: "Lunch with the team", Start: &calendar.EventDateTime{DateTime: "2017-11-08T12:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T13:00:00Z"}},
+			},
+			"40",
+			"▶ Qu… (ends 11:00) | next: Lun… in 2h50m\n",
+		},
+		{"truncatedLine", []*calendar.Event{standup, oneOnOne}, "10", "▶ … (ends…\n"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T09:10:00Z")()
+			api := getMockCalendarAPI(
+				t,
+				[]*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}},
+				map[string][]*calendar.Event{"primary": testCase.events},
+			)
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addStatusFlags(set)
+			assert.Nil(t, set.Set("cache-ttl", "0"))
+			if testCase.maxLength != "" {
+				assert.Nil(t, set.Set("max-length", testCase.maxLength))
+			}
+
+			assert.Nil(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected, writer.String())
+			assert.Equal(t, "2017-11-08T00:00:00Z", api.query("primary").Get("timeMin"))
+			assert.Equal(t, "2017-11-10T00:00:00Z", api.query("primary").Get("timeMax"))
+		})
+	}
+}
+
+func TestCmdStatusCache(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, "XDG_CACHE_HOME", filepath.Join(testFolder, "cache"))()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "America/Chicago"}},
+		map[string][]*calendar.Event{"primary": {{Id: "1", Summary: "Standup", Start: &calendar.EventDateTime{DateTime: "2017-11-08T15:00:00Z"}, End: &calendar.EventDateTime{DateTime: "2017-11-08T15:15:00Z"}}}},
+	)
+	defer api.Close()
+	var requests int32
+	api.eventsHook = func(calendarID string, w http.ResponseWriter, r *http.Request) bool {
+		atomic.AddInt32(&requests, 1)
+		return false
+	}
+
+	runStatus := func(now string) string {
+		defer setNow(t, now)()
+		app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+		addStatusFlags(set)
+		assert.Nil(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+		return writer.String()
+	}
+
+	assert.Equal(t, "next: Standup in 2m\n", runStatus("2017-11-08T14:58:00Z"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	matches, err := filepath.Glob(filepath.Join(testFolder, "cache", "calChecker", "status-*.json"))
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+
+	// Within the TTL the cached events are used, but the times are still relative to now
+	assert.Equal(t, "next: Standup in 1m\n", runStatus("2017-11-08T14:58:50Z"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	assert.Equal(t, "▶ Standup (ends 9:15)\n", runStatus("2017-11-08T15:00:30Z"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestCmdStatusCacheKey(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, "XDG_CACHE_HOME", filepath.Join(testFolder, "cache"))()
+	defer setNow(t, "2017-11-08T08:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{
+			{Id: "work", Selected: true, Primary: true, TimeZone: "UTC"},
+			{Id: "home", Selected: true, TimeZone: "UTC"},
+		},
+		map[string][]*calendar.Event{
+			"work": {{Id: "1", Summary: "Standup", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:00:00Z"}}},
+			"home": {{Id: "2", Summary: "Dentist", Start: &calendar.EventDateTime{DateTime: "2017-11-08T08:30:00Z"}}},
+		},
+	)
+	defer api.Close()
+	for _, testCase := range []struct{ calendar, expected string }{{"work", "next: Standup in 1h\n"}, {"home", "next: Dentist in 30m\n"}} {
+		app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+		addStatusFlags(set)
+		assert.Nil(t, set.Set("calendar", testCase.calendar))
+		assert.Nil(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)))
+		assert.Equal(t, testCase.expected, writer.String())
+	}
+}
+
+func TestCmdStatusNonInteractive(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}}, nil)
+	defer api.Close()
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addStatusFlags(set)
+	assert.Nil(t, set.Set("cache-ttl", "0"))
+	cb := &runner.Test{}
+	err := command.CmdStatus(cb)(cli.NewContext(app, set, nil))
+	assert.EqualError(t, err, "Could not get OAuth token: Authorization is required, run calChecker auth login")
+	exitErr, ok := err.(cli.ExitCoder)
+	assert.True(t, ok)
+	assert.Equal(t, command.ExitAuthorizationRequired, exitErr.ExitCode())
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.Equal(t, "", writer.String())
+}
+
+func TestCmdStatusInteractive(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	defer setNow(t, "2017-11-08T09:10:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Selected: true, Primary: true, TimeZone: "UTC"}},
+		map[string][]*calendar.Event{"primary": {{Id: "1", Summary: "1:1", Start: &calendar.EventDateTime{DateTime: "2017-11-08T09:52:00Z"}}}},
+	)
+	defer api.Close()
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	ec.Closure = func(command string) {
+		go func() {
+			_, err := http.Get(strings.Replace(command, "xdg-open ", "", -1))
+			assert.Nil(t, err)
+		}()
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	addStatusFlags(set)
+	assert.Nil(t, set.Set("cache-ttl", "0"))
+	assert.Nil(t, set.Set("interactive", "true"))
+	assert.Nil(t, command.CmdStatus(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
+	assert.Equal(t, []error(nil), cb.Errors)
+	assert.True(t, strings.HasPrefix(writer.String(), "Attempting to open "))
+	assert.True(t, strings.HasSuffix(writer.String(), "\nnext: 1:1 in 42m\n"))
+}
+
+func TestCmdStatusErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		flags map[string]string
+		args  []string
+		err   string
+	}{
+		{"usage", nil, []string{"foo"}, "Usage: \"calChecker status\""},
+		{"maxLength", map[string]string{"max-length": "-1"}, nil, "--max-length can not be negative"},
+		{"output", map[string]string{"output": "json"}, nil, "Invalid output format \"json\", expected one of text, waybar, i3bar"},
+		{"cacheTTL", map[string]string{"cache-ttl": "-1s"}, nil, "--cache-ttl can not be negative"},
+		{"interval", map[string]string{"interval": "0s"}, nil, "--interval must be positive"},
+		{"calendar", map[string]string{"calendar": "("}, nil, "Invalid --calendar pattern \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addStatusFlags(set)
+			for name, value := range testCase.flags {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			assert.Nil(t, set.Parse(testCase.args))
+			assert.EqualError(t, command.CmdStatus(&runner.Test{})(cli.NewContext(app, set, nil)), testCase.err)
+		})
+	}
+}
+
+func addStatusFlags(set *flag.FlagSet) {
+	set.Int("max-length", 60, "doc")
+	set.String("output", "text", "doc")
+	set.Bool("interactive", false, "doc")
+	set.Duration("cache-ttl", time.Minute, "doc")
+	set.Duration("interval", 30*time.Second, "doc")
+	set.String("timezone", "", "doc")
+	set.Var(&cli.StringSlice{}, "calendar", "doc")
+	set.Var(&cli.StringSlice{}, "exclude-calendar", "doc")
+	set.Int("concurrency", 4, "doc")
+}
+
+func TestCmdStatusFlagsBeforeCommand(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), nil)
+	defer api.Close()
+	c := getAuthorizedCommandContext(t, testFolder, "status", "--timezone", "Bogus/Zone")
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdStatus(cb)(c), `Invalid timezone "Bogus/Zone": unknown time zone Bogus/Zone`)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
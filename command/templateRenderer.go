@@ -0,0 +1,139 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var ansiColors = map[string]string{
+	"bold":    "1",
+	"dim":     "2",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+var templateFuncs = template.FuncMap{
+	"relative": relativeTime,
+	"duration": formatDuration,
+	"truncate": truncate,
+	"color":    color,
+	"join":     join,
+}
+
+// templateRenderer executes a user supplied template once for each event
+type templateRenderer struct {
+	template *template.Template
+}
+
+func (r templateRenderer) render(w io.Writer, events []*Event) error {
+	for _, event := range events {
+		err := r.template.Execute(w, event)
+		if err != nil {
+			return fmt.Errorf("Unable to render template: %v", err)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// parseEventTemplate parses the template given by --format or the contents of --template-file
+func parseEventTemplate(format, templateFile string) (*template.Template, error) {
+	name := "format"
+	if templateFile != "" {
+		contents, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, cli.NewExitError(fmt.Sprintf("Unable to read template file: %v", err), 1)
+		}
+
+		name = templateFile
+		format = strings.TrimSuffix(string(contents), "\n")
+	}
+
+	parsed, err := template.New(name).Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Invalid template: %v", err), 1)
+	}
+
+	return parsed, nil
+}
+
+// relativeTime describes when t is compared to now, like "in 5m" or "2h ago"
+func relativeTime(t time.Time) string {
+	difference := t.Sub(Now())
+	if difference >= -time.Minute && difference < time.Minute {
+		return "now"
+	}
+
+	if difference < 0 {
+		return fmt.Sprintf("%s ago", formatDuration(-difference))
+	}
+
+	return fmt.Sprintf("in %s", formatDuration(difference))
+}
+
+// formatDuration formats d in days, hours and minutes like "1h30m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "0m"
+	}
+
+	parts := ""
+	if days := d / (24 * time.Hour); days > 0 {
+		parts += fmt.Sprintf("%dd", days)
+		d -= days * 24 * time.Hour
+	}
+
+	if hours := d / time.Hour; hours > 0 {
+		parts += fmt.Sprintf("%dh", hours)
+		d -= hours * time.Hour
+	}
+
+	if minutes := d / time.Minute; minutes > 0 {
+		parts += fmt.Sprintf("%dm", minutes)
+	}
+
+	return parts
+}
+
+// truncate shortens value to at most length characters, ending it with an ellipsis when it was cut
+func truncate(length int, value string) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+
+	if length < 1 {
+		return ""
+	}
+
+	return string(runes[:length-1]) + "…"
+}
+
+// color wraps value in the ANSI escape codes for name
+func color(name, value string) (string, error) {
+	code, ok := ansiColors[name]
+	if !ok {
+		return "", fmt.Errorf("unknown color %q", name)
+	}
+
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, value), nil
+}
+
+// join joins values with separator
+func join(separator string, values []string) string {
+	return strings.Join(values, separator)
+}
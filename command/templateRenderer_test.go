@@ -0,0 +1,116 @@
+package command_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckTemplateFormat(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{"fields", `{{.Start.Format "15:04"}} {{.Summary}}`, "00:00 Offsite\n09:00 Standup\n18:30 Team dinner\n"},
+		{"relative", `{{.Summary}} {{relative .Start}}`, "Offsite 9h ago\nStandup now\nTeam dinner in 9h30m\n"},
+		{"duration", `{{.Summary}} {{duration .Duration}}`, "Offsite 1d\nStandup 15m\nTeam dinner 2h\n"},
+		{"truncate", `{{truncate 6 .Summary}}`, "Offsi…\nStand…\nTeam …\n"},
+		{"color", `{{color "red" .Summary}}`, "\x1b[31mOffsite\x1b[0m\n\x1b[31mStandup\x1b[0m\n\x1b[31mTeam dinner\x1b[0m\n"},
+		{"join", `{{.Summary}}: {{join ", " .Attendees}}`, "Offsite: \nStandup: boss@example.com, Me\nTeam dinner: \n"},
+		{"pipeline", `{{.Summary | truncate 4 | color "bold"}}`, "\x1b[1mOff…\x1b[0m\n\x1b[1mSta…\x1b[0m\n\x1b[1mTea…\x1b[0m\n"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, "2017-11-08T14:00:00Z")()
+			api := getMockCalendarAPI(t, getJSONTestCalendars(), getTemplateTestEvents())
+			defer api.Close()
+			app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			assert.Nil(t, set.Set("format", testCase.format))
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.expected, writer.String())
+		})
+	}
+}
+
+func TestCmdCheckTemplateFile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T14:00:00Z")()
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), getTemplateTestEvents())
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	templateFile := filepath.Join(testFolder, "agenda.tmpl")
+	assert.Nil(t, ioutil.WriteFile(templateFile, []byte("{{if .AllDay}}all day{{else}}{{.Start.Format \"3:04PM\"}}{{end}} {{.Summary}} ({{.CalendarLabel}})\n"), 0644))
+	assert.Nil(t, set.Set("template-file", templateFile))
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "all day Offsite (Team)\n9:00AM Standup (Me)\n6:30PM Team dinner (Team)\n", writer.String())
+}
+
+func TestCmdCheckTemplateUnknownColor(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T14:00:00Z")()
+	api := getMockCalendarAPI(t, getJSONTestCalendars(), getTemplateTestEvents())
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("format", `{{color "plaid" .Summary}}`))
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		`Unable to render template: template: format:1:2: executing "format" at <color "plaid" .Summary>: error calling color: unknown color "plaid"`,
+	)
+}
+
+func TestCmdCheckTemplateErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  map[string]string
+		error string
+	}{
+		{"format and file", map[string]string{"format": "{{.Summary}}", "template-file": "agenda.tmpl"}, "You cannot specify both --format and --template-file"},
+		{"json", map[string]string{"format": "{{.Summary}}", "output": "json"}, "You cannot use a template with --output json"},
+		{"invalid", map[string]string{"format": "{{.Summary"}, `Invalid template: template: format:1: unclosed action`},
+		{"missing file", map[string]string{"template-file": "/doesNotExist/agenda.tmpl"}, "Unable to read template file: open /doesNotExist/agenda.tmpl: no such file or directory"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+			addCheckFlags(set)
+			for name, value := range testCase.args {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			cb := &runner.Test{}
+			assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), testCase.error)
+			assert.Equal(t, []error(nil), cb.Errors)
+		})
+	}
+}
+
+func getTemplateTestEvents() map[string][]*calendar.Event {
+	events := getJSONTestEvents()
+	events["me@example.com"][0].Attendees[1].DisplayName = "Me"
+	return events
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// timezoneOverride returns the location given by --timezone or nil if it was not specified
+func timezoneOverride(c *cli.Context) (*time.Location, error) {
+	if c.String("timezone") == "" {
+		return nil, nil
+	}
+
+	loc, err := time.LoadLocation(c.String("timezone"))
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Invalid timezone %q: %v", c.String("timezone"), err), 1)
+	}
+
+	return loc, nil
+}
+
+// getLocation determines the timezone that day boundaries are computed in.
+// The primary calendar's timezone is used, falling back to the user's calendar settings and finally the local timezone.
+func getLocation(srv *calendar.Service, calendars []*calendar.CalendarListEntry) (*time.Location, error) {
+	for _, item := range calendars {
+		if item.Primary && item.TimeZone != "" {
+			return loadCalendarLocation(item.TimeZone)
+		}
+	}
+
+	setting, err := srv.Settings.Get("timezone").Do()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to check calendar settings. %v", err)
+	}
+
+	if setting.Value == "" {
+		return time.Local, nil
+	}
+
+	return loadCalendarLocation(setting.Value)
+}
+
+func loadCalendarLocation(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load calendar timezone %q: %v", name, err)
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,122 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestCmdCheckTimezone(t *testing.T) {
+	cases := []struct {
+		name             string
+		now              string
+		args             map[string]string
+		calendarTimezone string
+		settingsTimezone string
+		timeMin          string
+		timeMax          string
+	}{
+		{"override", "2017-11-08T10:00:00Z", map[string]string{"timezone": "America/New_York"}, "UTC", "", "2017-11-08T00:00:00-05:00", "2017-11-09T00:00:00-05:00"},
+		{"calendar", "2017-11-08T10:00:00Z", nil, "Europe/Berlin", "", "2017-11-08T00:00:00+01:00", "2017-11-09T00:00:00+01:00"},
+		{"settings", "2017-11-08T10:00:00Z", nil, "", "Asia/Tokyo", "2017-11-08T00:00:00+09:00", "2017-11-09T00:00:00+09:00"},
+		{"today west of UTC", "2017-11-08T03:00:00Z", nil, "America/Los_Angeles", "", "2017-11-07T00:00:00-08:00", "2017-11-08T00:00:00-08:00"},
+		{"today east of UTC", "2017-11-08T20:00:00Z", nil, "Asia/Tokyo", "", "2017-11-09T00:00:00+09:00", "2017-11-10T00:00:00+09:00"},
+		{"spring forward", "2018-03-11T12:00:00Z", nil, "America/New_York", "", "2018-03-11T00:00:00-05:00", "2018-03-12T00:00:00-04:00"},
+		{"fall back", "2017-11-05T12:00:00Z", nil, "America/New_York", "", "2017-11-05T00:00:00-04:00", "2017-11-06T00:00:00-05:00"},
+		{
+			"range across DST",
+			"2018-03-10T12:00:00Z",
+			map[string]string{"days": "3"},
+			"America/New_York",
+			"",
+			"2018-03-10T00:00:00-05:00",
+			"2018-03-13T00:00:00-04:00",
+		},
+		{
+			"date after DST",
+			"2018-03-10T12:00:00Z",
+			map[string]string{"date": "monday"},
+			"America/New_York",
+			"",
+			"2018-03-12T00:00:00-04:00",
+			"2018-03-13T00:00:00-04:00",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			defer removeFile(t, testFolder)
+			defer setNow(t, testCase.now)()
+			api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: testCase.calendarTimezone}}, nil)
+			defer api.Close()
+			api.timezone = testCase.settingsTimezone
+			app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+			addCheckFlags(set)
+			for name, value := range testCase.args {
+				assert.Nil(t, set.Set(name, value))
+			}
+
+			assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+			assert.Equal(t, testCase.timeMin, api.query("primary").Get("timeMin"))
+			assert.Equal(t, testCase.timeMax, api.query("primary").Get("timeMax"))
+		})
+	}
+}
+
+func TestCmdCheckTimezoneEventTimes(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setNow(t, "2017-11-08T03:00:00Z")()
+	api := getMockCalendarAPI(
+		t,
+		[]*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "America/Los_Angeles"}},
+		map[string][]*calendar.Event{
+			"primary": {
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-07T09:00:00-08:00"}, Summary: "Standup"},
+				{Start: &calendar.EventDateTime{DateTime: "2017-11-08T01:30:00Z"}, Summary: "Dinner"},
+			},
+		},
+	)
+	defer api.Close()
+	app, writer, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Equal(t, "Tue, 9:00AM  Standup\nTue, 5:30PM  Dinner\n", writer.String())
+}
+
+func TestCmdCheckInvalidTimezone(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, "")
+	addCheckFlags(set)
+	assert.Nil(t, set.Set("timezone", "Mars/Olympus_Mons"))
+	cb := &runner.Test{}
+	assert.EqualError(t, command.CmdCheck(cb)(cli.NewContext(app, set, nil)), `Invalid timezone "Mars/Olympus_Mons": unknown time zone Mars/Olympus_Mons`)
+	assert.Equal(t, []error(nil), cb.Errors)
+}
+
+func TestCmdCheckInvalidCalendarTimezone(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	api := getMockCalendarAPI(t, []*calendar.CalendarListEntry{{Id: "primary", Primary: true, Selected: true, TimeZone: "Mars/Olympus_Mons"}}, nil)
+	defer api.Close()
+	app, _, set := getAuthorizedAppAndFlagSet(t, testFolder)
+	addCheckFlags(set)
+	assert.EqualError(
+		t,
+		command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)),
+		`Unable to load calendar timezone "Mars/Olympus_Mons": unknown time zone Mars/Olympus_Mons`,
+	)
+}
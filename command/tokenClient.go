@@ -2,36 +2,60 @@ package command
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"net/http/httptest"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/guywithnose/runner"
+	"github.com/urfave/cli"
 
 	calendar "google.golang.org/api/calendar/v3"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
 )
 
-// Client gets a token for a user
+// The defaults of --browser-command and --redirect-host
+const (
+	DefaultBrowserCommand = "xdg-open {url}"
+	DefaultRedirectHost   = "127.0.0.1"
+)
+
+// Client gets a token for a user, a service account or from Application Default Credentials
 type Client struct {
-	config         *oauth2.Config
-	tokenCacheFile string
-	cmdBuilder     runner.Builder
+	config             *oauth2.Config
+	jwtConfig          *jwt.Config
+	defaultCredentials bool
+	tokenCacheFile     string
+	store              TokenStore
+	cmdBuilder         runner.Builder
+	options            authOptions
 }
 
-// NewClient returns a Client
+// NewClient returns a Client for either an installed app credential file or a service account key
 func NewClient(appCredentialFile, tokenCacheFile string, cmdBuilder runner.Builder) (*Client, error) {
 	appCredentials, err := ioutil.ReadFile(appCredentialFile)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to read app credential file: %v", err)
 	}
 
+	if credentialType(appCredentials) == serviceAccountType {
+		jwtConfig, err := google.JWTConfigFromJSON(appCredentials, calendar.CalendarReadonlyScope)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse service account credentials: %v", err)
+		}
+
+		return &Client{jwtConfig: jwtConfig, cmdBuilder: cmdBuilder}, nil
+	}
+
 	config, err := google.ConfigFromJSON(appCredentials, calendar.CalendarReadonlyScope)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse app credentials: %v", err)
@@ -40,50 +64,142 @@ func NewClient(appCredentialFile, tokenCacheFile string, cmdBuilder runner.Build
 	return &Client{
 		config:         config,
 		tokenCacheFile: tokenCacheFile,
+		store:          NewFileTokenStore(tokenCacheFile, os.Stderr),
 		cmdBuilder:     cmdBuilder,
 	}, nil
 }
 
+// NewDefaultClient returns a Client that uses Application Default Credentials
+func NewDefaultClient(cmdBuilder runner.Builder) *Client {
+	return &Client{defaultCredentials: true, cmdBuilder: cmdBuilder}
+}
+
+// SetTokenStore replaces where the user's token is loaded from and saved to
+func (client *Client) SetTokenStore(store TokenStore) {
+	client.store = store
+}
+
 // GetHTTPClient gets an oauth token.  If necessary it may open a browser for user authorization.
 func (client Client) GetHTTPClient(writer io.Writer) (*http.Client, error) {
-	token, err := client.tokenFromFile()
+	if client.jwtConfig != nil {
+		return client.serviceAccountClient(client.jwtConfig), nil
+	}
+
+	if client.defaultCredentials {
+		return client.defaultCredentialsClient()
+	}
+
+	token, err := client.store.Load()
+	// A token file that exists but can not be decrypted must not be replaced by a new login
+	if _, ok := err.(cli.ExitCoder); ok {
+		return nil, err
+	}
+
 	if err != nil {
+		if client.options.nonInteractive {
+			return nil, cli.NewExitError("Authorization is required, run calChecker auth login", ExitAuthorizationRequired)
+		}
+
 		token, err = client.getTokenFromWeb(writer)
 		if err != nil {
 			return nil, err
 		}
 
-		err = client.saveToken(token)
+		err = client.store.Save(token)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return client.config.Client(context.Background(), token), err
+	return oauth2.NewClient(context.Background(), client.tokenSource(token)), nil
+}
+
+// tokenSource refreshes token when it expires, saving each new token to the token store
+func (client Client) tokenSource(token *oauth2.Token) oauth2.TokenSource {
+	return &persistingTokenSource{
+		base:   client.config.TokenSource(context.Background(), token),
+		client: client,
+		last:   token,
+	}
+}
+
+// persistingTokenSource writes tokens back to the token store whenever they are refreshed
+type persistingTokenSource struct {
+	base   oauth2.TokenSource
+	client Client
+	mutex  sync.Mutex
+	last   *oauth2.Token
+}
+
+// Token returns a valid token, saving it if it changed since the last call
+func (source *persistingTokenSource) Token() (*oauth2.Token, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	token, err := source.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenChanged(source.last, token) {
+		err = source.client.store.Save(token)
+		if err != nil {
+			return nil, err
+		}
+
+		source.last = token
+	}
+
+	return token, nil
+}
+
+func tokenChanged(previous, current *oauth2.Token) bool {
+	return previous.AccessToken != current.AccessToken || previous.RefreshToken != current.RefreshToken || !previous.Expiry.Equal(current.Expiry)
+}
+
+// Login authorizes calChecker and saves the new token, replacing any existing one.
+// With device set the device authorization flow is used instead of a browser on this machine.
+func (client Client) Login(writer io.Writer, device bool) error {
+	if client.config == nil {
+		return fmt.Errorf("Service accounts and application default credentials do not need to log in")
+	}
+
+	getToken := client.getTokenFromWeb
+	if device {
+		getToken = client.getTokenFromDevice
+	}
+
+	token, err := getToken(writer)
+	if err != nil {
+		return err
+	}
+
+	return client.store.Save(token)
 }
 
 // getTokenFromWeb uses Config to request a Token.
 // It returns the retrieved Token.
 func (client Client) getTokenFromWeb(writer io.Writer) (*oauth2.Token, error) {
-	token := make(chan string)
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("Your inbox should now be authorized.  You may close this window."))
-		token <- r.FormValue("code")
-	}))
-	client.config.RedirectURL = server.URL
-
-	authURL := client.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Fprintf(writer, "Attempting to open %s in your browser\n", authURL)
-	cmd := client.cmdBuilder.New("", "xdg-open", authURL)
-	_, err := cmd.CombinedOutput()
+	state, err := randomString()
 	if err != nil {
-		fmt.Fprintf(writer, "Unable to open browser automatically: %v\nPlease open %s in your browser\n", err, authURL)
+		return nil, err
 	}
 
-	code := <-token
-	server.Close()
+	proof, err := newPKCE()
+	if err != nil {
+		return nil, err
+	}
 
-	tok, err := client.config.Exchange(context.Background(), code)
+	getCode := client.codeFromCallback
+	if client.options.noBrowser {
+		getCode = client.codeFromPaste
+	}
+
+	code, err := getCode(writer, state, proof)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := client.config.Exchange(proof.exchangeContext(context.Background()), code)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to retrieve token from web: %v", err)
 	}
@@ -91,27 +207,82 @@ func (client Client) getTokenFromWeb(writer io.Writer) (*oauth2.Token, error) {
 	return tok, nil
 }
 
-// tokenFromFile retrieves a Token from a given file path.
-// It returns the retrieved Token and any read error encountered.
-func (client Client) tokenFromFile() (*oauth2.Token, error) {
-	f, err := os.Open(client.tokenCacheFile)
+// codeFromCallback opens the authorization URL in a browser and waits for it to redirect back with the code
+func (client Client) codeFromCallback(writer io.Writer, state string, proof pkce) (string, error) {
+	// Watch for interrupts before the browser opens so an early Ctrl-C still shuts down the server
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	handler := newCallbackHandler(state)
+	redirectURL, shutdown, err := client.listenForCallback(handler)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	defer shutdown()
+	client.config.RedirectURL = redirectURL
+
+	authURL := client.authCodeURL(state, proof)
+	fmt.Fprintf(writer, "Attempting to open %s in your browser\n", authURL)
+	err = client.openBrowser(authURL)
+	if err != nil {
+		fmt.Fprintf(writer, "Unable to open browser automatically: %v\nPlease open %s in your browser\n", err, authURL)
 	}
-	t := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(t)
-	_ = f.Close()
-	return t, err
+
+	return handler.wait(client.options.timeout, interrupts)
 }
 
-// saveToken uses a file path to create a file and store the
-// token in it.
-func (client Client) saveToken(token *oauth2.Token) error {
-	f, err := os.OpenFile(client.tokenCacheFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+// listenForCallback serves handler on the redirect host and port, a port of 0 picks any free port.
+// It returns the redirect URL and a function that stops the server.
+func (client Client) listenForCallback(handler http.Handler) (string, func(), error) {
+	host := client.options.redirectHost
+	if host == "" {
+		host = DefaultRedirectHost
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(client.options.redirectPort)))
 	if err != nil {
-		return fmt.Errorf("Unable to cache oauth token: %v", err)
+		return "", nil, fmt.Errorf("Unable to listen for the authorization redirect: %v", err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	redirectURL := "http://" + net.JoinHostPort(host, port) + callbackPath
+	return redirectURL, func() { _ = server.Close() }, nil
+}
+
+// openBrowser runs the browser command with {url} replaced by authURL.  A command without {url} gets it as its last argument.
+func (client Client) openBrowser(authURL string) error {
+	template := client.options.browserCommand
+	if template == "" {
+		template = DefaultBrowserCommand
 	}
-	err = json.NewEncoder(f).Encode(token)
-	_ = f.Close()
+
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return fmt.Errorf("the browser command is empty")
+	}
+
+	hasURL := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{url}") {
+			args[i] = strings.Replace(arg, "{url}", authURL, -1)
+			hasURL = true
+		}
+	}
+
+	if !hasURL {
+		args = append(args, authURL)
+	}
+
+	_, err := client.cmdBuilder.New("", args...).CombinedOutput()
 	return err
 }
+
+func (client Client) authCodeURL(state string, proof pkce) string {
+	return client.config.AuthCodeURL(state, append(proof.authCodeOptions(), oauth2.AccessTypeOffline)...)
+}
@@ -2,13 +2,18 @@ package command_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 
 	"github.com/guywithnose/calChecker/command"
 	"github.com/guywithnose/runner"
@@ -92,7 +97,7 @@ func TestGetHTTPCLientInvalidTokenFile(t *testing.T) {
 	assert.NotNil(t, client)
 	writer := &bytes.Buffer{}
 	httpClient, err := client.GetHTTPClient(writer)
-	assert.EqualError(t, err, "Unable to cache oauth token: open /tmp/testCalChecker/doesntexist/token: no such file or directory")
+	assert.Regexp(t, `^Unable to cache oauth token: open /tmp/testCalChecker/doesntexist/token\.\d+\.tmp: no such file or directory$`, err)
 	assert.Nil(t, httpClient)
 	assert.Equal(t, []*runner.ExpectedCommand{}, cb.ExpectedCommands)
 	assert.Equal(t, []error(nil), cb.Errors)
@@ -104,7 +109,7 @@ func TestGetHTTPCLientLoadFromCacheFile(t *testing.T) {
 	assert.Nil(t, os.MkdirAll(testFolder, 0777))
 	credentialFile := filepath.Join(testFolder, "credentials")
 	tokenCacheFile := filepath.Join(testFolder, "token")
-	assert.Nil(t, ioutil.WriteFile(tokenCacheFile, []byte("{\"access_token\":\"fakeToken\",\"expiry\":\"0001-01-01T00:00:00Z\"}\n"), 0777))
+	assert.Nil(t, ioutil.WriteFile(tokenCacheFile, []byte("{\"access_token\":\"fakeToken\",\"expiry\":\"0001-01-01T00:00:00Z\"}\n"), 0600))
 	ts := getMockGoogleAPI(t)
 	defer ts.Close()
 	assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
@@ -119,6 +124,96 @@ func TestGetHTTPCLientLoadFromCacheFile(t *testing.T) {
 	assert.Equal(t, []error(nil), cb.Errors)
 }
 
+func TestGetHTTPCLientPersistsRefreshedToken(t *testing.T) {
+	cases := []struct {
+		name         string
+		response     string
+		refreshToken string
+	}{
+		{"rotated refresh token", `{"access_token":"newToken","refresh_token":"newRefresh","expires_in":3600,"token_type":"Bearer"}`, "newRefresh"},
+		{"same refresh token", `{"access_token":"newToken","expires_in":3600,"token_type":"Bearer"}`, "oldRefresh"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+			defer removeFile(t, testFolder)
+			assert.Nil(t, os.MkdirAll(testFolder, 0777))
+			credentialFile := filepath.Join(testFolder, "credentials")
+			tokenCacheFile := filepath.Join(testFolder, "token")
+			expired := `{"access_token":"oldToken","refresh_token":"oldRefresh","expiry":"2017-11-08T08:00:00Z"}`
+			assert.Nil(t, ioutil.WriteFile(tokenCacheFile, []byte(expired), 0600))
+			var authorization string
+			ts := getMockTokenRefreshAPI(t, testCase.response, &authorization)
+			defer ts.Close()
+			assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
+			client, err := command.NewClient(credentialFile, tokenCacheFile, &runner.Test{})
+			assert.Nil(t, err)
+			httpClient, err := client.GetHTTPClient(&bytes.Buffer{})
+			assert.Nil(t, err)
+			response, err := httpClient.Get(ts.URL + "/calendar")
+			assert.Nil(t, err)
+			assert.Nil(t, response.Body.Close())
+			assert.Equal(t, "Bearer newToken", authorization)
+			token := readTokenFile(t, tokenCacheFile)
+			assert.Equal(t, "newToken", token.AccessToken)
+			assert.Equal(t, testCase.refreshToken, token.RefreshToken)
+			assert.True(t, token.Expiry.After(time.Now()))
+			info, err := os.Stat(tokenCacheFile)
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			files, err := ioutil.ReadDir(testFolder)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(files))
+		})
+	}
+}
+
+func TestGetHTTPCLientDoesNotRewriteUnchangedToken(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	defer removeFile(t, testFolder)
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	credentialFile := filepath.Join(testFolder, "credentials")
+	tokenCacheFile := filepath.Join(testFolder, "token")
+	valid := `{"access_token":"fakeToken","refresh_token":"refresh","expiry":"2999-01-01T00:00:00Z"}`
+	assert.Nil(t, ioutil.WriteFile(tokenCacheFile, []byte(valid), 0600))
+	var authorization string
+	ts := getMockTokenRefreshAPI(t, "", &authorization)
+	defer ts.Close()
+	assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
+	client, err := command.NewClient(credentialFile, tokenCacheFile, &runner.Test{})
+	assert.Nil(t, err)
+	httpClient, err := client.GetHTTPClient(&bytes.Buffer{})
+	assert.Nil(t, err)
+	response, err := httpClient.Get(ts.URL + "/calendar")
+	assert.Nil(t, err)
+	assert.Nil(t, response.Body.Close())
+	assert.Equal(t, "Bearer fakeToken", authorization)
+	tokenFileContents, _ := ioutil.ReadFile(tokenCacheFile)
+	assert.Equal(t, valid, string(tokenFileContents))
+}
+
+func TestGetHTTPCLientRefreshFailure(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	defer removeFile(t, testFolder)
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	credentialFile := filepath.Join(testFolder, "credentials")
+	tokenCacheFile := filepath.Join(testFolder, "token")
+	expired := `{"access_token":"oldToken","refresh_token":"oldRefresh","expiry":"2017-11-08T08:00:00Z"}`
+	assert.Nil(t, ioutil.WriteFile(tokenCacheFile, []byte(expired), 0600))
+	ts := getMockGoogleAPITokenFailure(t)
+	defer ts.Close()
+	assert.Nil(t, ioutil.WriteFile(credentialFile, getTestCredentials(ts.URL), 0777))
+	client, err := command.NewClient(credentialFile, tokenCacheFile, &runner.Test{})
+	assert.Nil(t, err)
+	httpClient, err := client.GetHTTPClient(&bytes.Buffer{})
+	assert.Nil(t, err)
+	_, err = httpClient.Get(ts.URL + "/calendar")
+	assert.NotNil(t, err)
+	tokenFileContents, _ := ioutil.ReadFile(tokenCacheFile)
+	assert.Equal(t, expired, string(tokenFileContents))
+}
+
 func TestGetHTTPCLientUnableToOpenBrowser(t *testing.T) {
 	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
 	defer removeFile(t, testFolder)
@@ -181,6 +276,28 @@ func TestGetHTTPCLientCorruptCredentialFile(t *testing.T) {
 	assert.Equal(t, []error(nil), cb.Errors)
 }
 
+func getMockTokenRefreshAPI(t *testing.T, refreshResponse string, authorization *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") == "refresh_token" {
+			assert.Equal(t, "oldRefresh", r.FormValue("refresh_token"))
+			w.Header().Set("Content-Type", "application/json")
+			_, err := w.Write([]byte(refreshResponse))
+			assert.Nil(t, err)
+			return
+		}
+
+		*authorization = r.Header.Get("Authorization")
+	}))
+}
+
+func readTokenFile(t *testing.T, tokenCacheFile string) *oauth2.Token {
+	contents, err := ioutil.ReadFile(tokenCacheFile)
+	assert.Nil(t, err)
+	token := &oauth2.Token{}
+	assert.Nil(t, json.Unmarshal(contents, token))
+	return token
+}
+
 func TestHelperProcess(*testing.T) {
 	runner.ErrorCodeHelper()
 }
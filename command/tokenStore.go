@@ -0,0 +1,322 @@
+package command
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/oauth2"
+)
+
+// TokenPassphraseEnvVar names the environment variable holding the passphrase for an encrypted token file
+const TokenPassphraseEnvVar = "CALCHECKER_TOKEN_PASSPHRASE"
+
+// The scrypt parameters recommended for interactive logins
+const (
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// TokenStore loads and saves the user's OAuth token
+type TokenStore interface {
+	// Load returns the saved token, or an error satisfying os.IsNotExist when there is none
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+	Delete() error
+}
+
+// FileTokenStore keeps the token as JSON in a file only the user can read
+type FileTokenStore struct {
+	path     string
+	warnings io.Writer
+}
+
+// NewFileTokenStore returns a FileTokenStore that writes any permission warnings to warnings
+func NewFileTokenStore(path string, warnings io.Writer) *FileTokenStore {
+	return &FileTokenStore{path: path, warnings: warnings}
+}
+
+// Load reads the token file, first restricting its permissions if other users can read it
+func (store *FileTokenStore) Load() (*oauth2.Token, error) {
+	restrictPermissions(store.path, store.warnings)
+	contents, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{}
+	err = json.Unmarshal(contents, token)
+	return token, err
+}
+
+// Save replaces the token file
+func (store *FileTokenStore) Save(token *oauth2.Token) error {
+	contents, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	return writeTokenFile(store.path, append(contents, '\n'))
+}
+
+// Delete removes the token file
+func (store *FileTokenStore) Delete() error {
+	return os.Remove(store.path)
+}
+
+// EncryptedTokenStore keeps the token in a file encrypted with AES-GCM using a key derived from a passphrase by scrypt
+type EncryptedTokenStore struct {
+	path       string
+	warnings   io.Writer
+	passphrase func() (string, error)
+	mutex      sync.Mutex
+	cached     string
+}
+
+// encryptedToken is the contents of an encrypted token file
+type encryptedToken struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewEncryptedTokenStore returns an EncryptedTokenStore.  passphrase is called at most once, when the key is first needed.
+func NewEncryptedTokenStore(path string, warnings io.Writer, passphrase func() (string, error)) *EncryptedTokenStore {
+	return &EncryptedTokenStore{path: path, warnings: warnings, passphrase: passphrase}
+}
+
+// Load decrypts the token file.  A plaintext token file is encrypted in place.
+func (store *EncryptedTokenStore) Load() (*oauth2.Token, error) {
+	restrictPermissions(store.path, store.warnings)
+	contents, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &encryptedToken{}
+	err = json.Unmarshal(contents, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.KDF == "" {
+		return store.encryptPlaintext(contents)
+	}
+
+	if envelope.KDF != "scrypt" {
+		return nil, cli.NewExitError(fmt.Sprintf("Unsupported token file key derivation %q", envelope.KDF), 1)
+	}
+
+	aead, err := store.cipher(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, cli.NewExitError("Unable to decrypt the token file, check the passphrase", 1)
+	}
+
+	token := &oauth2.Token{}
+	err = json.Unmarshal(plaintext, token)
+	return token, err
+}
+
+// Save encrypts the token with a new salt and nonce and replaces the token file
+func (store *EncryptedTokenStore) Save(token *oauth2.Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	envelope := &encryptedToken{KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, 16)}
+	_, err = rand.Read(envelope.Salt)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	aead, err := store.cipher(envelope)
+	if err != nil {
+		return err
+	}
+
+	envelope.Nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(envelope.Nonce)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, nil)
+	contents, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	return writeTokenFile(store.path, append(contents, '\n'))
+}
+
+// Delete removes the token file
+func (store *EncryptedTokenStore) Delete() error {
+	return os.Remove(store.path)
+}
+
+func (store *EncryptedTokenStore) encryptPlaintext(contents []byte) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	err := json.Unmarshal(contents, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.Save(token)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(store.warnings, "Encrypted the plaintext token file %s\n", store.path)
+	return token, nil
+}
+
+// cipher derives the key for envelope's salt and parameters from the passphrase.  Only the parameters calChecker
+// writes are accepted, so a token file can not make it spend unbounded memory and time deriving the key.
+func (store *EncryptedTokenStore) cipher(envelope *encryptedToken) (cipher.AEAD, error) {
+	if envelope.N != scryptN || envelope.R != scryptR || envelope.P != scryptP {
+		return nil, cli.NewExitError(
+			fmt.Sprintf("Unsupported token file scrypt parameters N=%d r=%d p=%d", envelope.N, envelope.R, envelope.P),
+			1,
+		)
+	}
+
+	passphrase, err := store.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), envelope.Salt, envelope.N, envelope.R, envelope.P, 32)
+	if err != nil {
+		return nil, cli.NewExitError(fmt.Sprintf("Unable to derive the token file key: %v", err), 1)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (store *EncryptedTokenStore) getPassphrase() (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.cached != "" {
+		return store.cached, nil
+	}
+
+	passphrase, err := store.passphrase()
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase == "" {
+		return "", cli.NewExitError("The token passphrase can not be empty", 1)
+	}
+
+	store.cached = passphrase
+	return passphrase, nil
+}
+
+// passphraseFromEnvOrTerminal returns a function that reads the passphrase from TokenPassphraseEnvVar, or asks for it
+// on the terminal without echoing it
+func passphraseFromEnvOrTerminal(prompt io.Writer) func() (string, error) {
+	return func() (string, error) {
+		if value := os.Getenv(TokenPassphraseEnvVar); value != "" {
+			return value, nil
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !terminal.IsTerminal(fd) {
+			return "", cli.NewExitError(
+				fmt.Sprintf("The token file is encrypted, set %s or run calChecker in a terminal to enter the passphrase", TokenPassphraseEnvVar),
+				1,
+			)
+		}
+
+		fmt.Fprint(prompt, "Token file passphrase: ")
+		value, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", cli.NewExitError(fmt.Sprintf("Unable to read the passphrase: %v", err), 1)
+		}
+
+		return string(value), nil
+	}
+}
+
+// isEncryptedTokenFile reports whether path holds an encrypted token
+func isEncryptedTokenFile(path string) bool {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	envelope := &encryptedToken{}
+	return json.Unmarshal(contents, envelope) == nil && envelope.KDF != ""
+}
+
+// restrictPermissions makes an existing token file readable only by its owner, warning when it was not
+func restrictPermissions(path string, warnings io.Writer) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0077 == 0 {
+		return
+	}
+
+	fmt.Fprintf(warnings, "Warning: the token file %s could be read by other users (mode %04o), changing it to 0600\n", path, info.Mode().Perm())
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		fmt.Fprintf(warnings, "Warning: unable to change the permissions of %s: %v\n", path, err)
+	}
+}
+
+// writeTokenFile writes contents to a temporary file which then replaces the token file,
+// so a failed write never leaves it truncated
+func writeTokenFile(path string, contents []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	_, err = f.Write(contents)
+	if err == nil {
+		err = f.Chmod(0600)
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package command_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guywithnose/calChecker/command"
+	"github.com/guywithnose/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+	"golang.org/x/oauth2"
+)
+
+func TestEncryptedTokenStore(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	warnings := new(bytes.Buffer)
+	store := command.NewEncryptedTokenStore(tokenFile, warnings, passphrase("secret"))
+	require.Nil(t, store.Save(&oauth2.Token{AccessToken: "accessSecret", RefreshToken: "refreshSecret"}))
+	contents, err := ioutil.ReadFile(tokenFile)
+	require.Nil(t, err)
+	assert.Contains(t, string(contents), `"kdf":"scrypt"`)
+	assert.NotContains(t, string(contents), "accessSecret")
+	assert.NotContains(t, string(contents), "refreshSecret")
+	info, err := os.Stat(tokenFile)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	token, err := command.NewEncryptedTokenStore(tokenFile, warnings, passphrase("secret")).Load()
+	require.Nil(t, err)
+	assert.Equal(t, "accessSecret", token.AccessToken)
+	assert.Equal(t, "refreshSecret", token.RefreshToken)
+	assert.Equal(t, "", warnings.String())
+}
+
+func TestEncryptedTokenStoreWrongPassphrase(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	require.Nil(t, command.NewEncryptedTokenStore(tokenFile, ioutil.Discard, passphrase("secret")).Save(&oauth2.Token{AccessToken: "accessSecret"}))
+	_, err := command.NewEncryptedTokenStore(tokenFile, ioutil.Discard, passphrase("wrong")).Load()
+	assert.EqualError(t, err, "Unable to decrypt the token file, check the passphrase")
+}
+
+func TestEncryptedTokenStoreUnsupportedParameters(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	require.Nil(t, command.NewEncryptedTokenStore(tokenFile, ioutil.Discard, passphrase("secret")).Save(&oauth2.Token{AccessToken: "accessSecret"}))
+	contents, err := ioutil.ReadFile(tokenFile)
+	require.Nil(t, err)
+	require.Contains(t, string(contents), `"n":32768,`)
+	require.Nil(t, ioutil.WriteFile(tokenFile, []byte(strings.Replace(string(contents), `"n":32768,`, `"n":1073741824,`, 1)), 0600))
+	_, err = command.NewEncryptedTokenStore(tokenFile, ioutil.Discard, passphrase("secret")).Load()
+	assert.EqualError(t, err, "Unsupported token file scrypt parameters N=1073741824 r=8 p=1")
+}
+
+func TestEncryptedTokenStoreEmptyPassphrase(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	store := command.NewEncryptedTokenStore(filepath.Join(testFolder, "tokenFile"), ioutil.Discard, passphrase(""))
+	assert.EqualError(t, store.Save(&oauth2.Token{AccessToken: "accessSecret"}), "The token passphrase can not be empty")
+}
+
+func TestEncryptedTokenStoreEncryptsPlaintextFile(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte(`{"access_token":"accessSecret","refresh_token":"refreshSecret"}`), 0600))
+	warnings := new(bytes.Buffer)
+	token, err := command.NewEncryptedTokenStore(tokenFile, warnings, passphrase("secret")).Load()
+	require.Nil(t, err)
+	assert.Equal(t, "refreshSecret", token.RefreshToken)
+	assert.Equal(t, "Encrypted the plaintext token file /tmp/testCalChecker/tokenFile\n", warnings.String())
+	contents, err := ioutil.ReadFile(tokenFile)
+	require.Nil(t, err)
+	assert.NotContains(t, string(contents), "refreshSecret")
+}
+
+func TestFileTokenStoreRestrictsPermissions(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte(`{"access_token":"accessSecret"}`), 0600))
+	assert.Nil(t, os.Chmod(tokenFile, 0644))
+	warnings := new(bytes.Buffer)
+	token, err := command.NewFileTokenStore(tokenFile, warnings).Load()
+	require.Nil(t, err)
+	assert.Equal(t, "accessSecret", token.AccessToken)
+	assert.Equal(
+		t,
+		"Warning: the token file /tmp/testCalChecker/tokenFile could be read by other users (mode 0644), changing it to 0600\n",
+		warnings.String(),
+	)
+	info, err := os.Stat(tokenFile)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestCmdAuthLoginEncryptToken(t *testing.T) {
+	testFolder := filepath.Join(os.TempDir(), "testCalChecker")
+	assert.Nil(t, os.MkdirAll(testFolder, 0777))
+	defer removeFile(t, testFolder)
+	defer setEnv(t, command.TokenPassphraseEnvVar, "secret")()
+	ts := getMockGoogleAPI(t)
+	defer ts.Close()
+	command.BasePath = ts.URL
+	app, _, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	set.Bool("device", false, "doc")
+	set.Bool("encrypt-token", true, "doc")
+	ec := runner.NewExpectedCommand("", "xdg-open.*", "", 0)
+	ec.Closure = func(command string) {
+		getStatus(t, strings.Replace(command, "xdg-open ", "", -1))
+	}
+	cb := &runner.Test{ExpectedCommands: []*runner.ExpectedCommand{ec}}
+	assert.Nil(t, command.CmdAuthLogin(cb)(cli.NewContext(app, set, nil)))
+	assert.Equal(t, []error(nil), cb.Errors)
+	tokenFile := filepath.Join(testFolder, "tokenFile")
+	contents, err := ioutil.ReadFile(tokenFile)
+	require.Nil(t, err)
+	assert.NotContains(t, string(contents), "fakeToken")
+
+	// An encrypted token file stays encrypted without the flag
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, ts.URL)
+	assert.Nil(t, command.CmdCheck(&runner.Test{})(cli.NewContext(app, set, nil)))
+	assert.Contains(t, writer.String(), "Something is going to happen")
+}
+
+func passphrase(value string) func() (string, error) {
+	return func() (string, error) {
+		return value, nil
+	}
+}
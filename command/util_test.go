@@ -2,20 +2,168 @@ package command_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/guywithnose/calChecker/command"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli"
+	"golang.org/x/oauth2"
+	calendar "google.golang.org/api/calendar/v3"
 )
 
+var update = flag.Bool("update", false, "update golden files")
+
 func appWithTestWriters() (*cli.App, *bytes.Buffer) {
 	app := cli.NewApp()
 	writer := new(bytes.Buffer)
 	app.Writer = writer
+	app.ErrWriter = ioutil.Discard
 	return app, writer
 }
 
 func removeFile(t *testing.T, fileName string) {
 	assert.Nil(t, os.RemoveAll(fileName))
 }
+
+// mockCalendarAPI serves calendar lists and events and records the events queries it receives
+type mockCalendarAPI struct {
+	*httptest.Server
+	calendars    []*calendar.CalendarListEntry
+	events       map[string][]*calendar.Event
+	timezone     string
+	pageSize     int
+	eventsHook   func(calendarID string, w http.ResponseWriter, r *http.Request) bool
+	mutex        sync.Mutex
+	eventQueries map[string]url.Values
+}
+
+func getMockCalendarAPI(t *testing.T, calendars []*calendar.CalendarListEntry, events map[string][]*calendar.Event) *mockCalendarAPI {
+	api := &mockCalendarAPI{calendars: calendars, events: events, eventQueries: map[string]url.Values{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch {
+		case r.URL.Path == "/users/me/calendarList":
+			resp = calendar.CalendarList{Items: api.calendars}
+		case r.URL.Path == "/users/me/settings/timezone":
+			resp = calendar.Setting{Id: "timezone", Value: api.timezone}
+		case strings.HasPrefix(r.URL.Path, "/calendars/") && strings.HasSuffix(r.URL.Path, "/events"):
+			calendarID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/calendars/"), "/events")
+			api.mutex.Lock()
+			api.eventQueries[calendarID] = r.URL.Query()
+			api.mutex.Unlock()
+			if api.eventsHook != nil && api.eventsHook(calendarID, w, r) {
+				return
+			}
+
+			resp = api.eventsPage(calendarID, r.FormValue("pageToken"))
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bytes, _ := json.Marshal(resp)
+		_, err := w.Write(bytes)
+		assert.Nil(t, err)
+	}))
+	command.BasePath = api.URL
+	return api
+}
+
+// eventsPage splits a calendar's events into pages of pageSize when it is set
+func (api *mockCalendarAPI) eventsPage(calendarID, pageToken string) calendar.Events {
+	items := api.events[calendarID]
+	if api.pageSize == 0 {
+		return calendar.Events{Items: items}
+	}
+
+	start, _ := strconv.Atoi(pageToken)
+	end := start + api.pageSize
+	if end >= len(items) {
+		return calendar.Events{Items: items[start:]}
+	}
+
+	return calendar.Events{Items: items[start:end], NextPageToken: strconv.Itoa(end)}
+}
+
+func (api *mockCalendarAPI) query(calendarID string) url.Values {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	return api.eventQueries[calendarID]
+}
+
+// getAuthorizedAppAndFlagSet returns an app with a cached token so no OAuth flow is needed
+func getAuthorizedAppAndFlagSet(t *testing.T, testFolder string) (*cli.App, *bytes.Buffer, *flag.FlagSet) {
+	app, writer, set := getBaseAppAndFlagSet(t, testFolder, "")
+	token, _ := json.Marshal(&oauth2.Token{AccessToken: "fakeToken", TokenType: "Bearer"})
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(testFolder, "tokenFile"), token, 0600))
+	return app, writer, set
+}
+
+func setNow(t *testing.T, value string) func() {
+	now, err := time.Parse(time.RFC3339, value)
+	assert.Nil(t, err)
+	command.Now = func() time.Time { return now }
+	return func() { command.Now = time.Now }
+}
+
+func addCheckFlags(set *flag.FlagSet) {
+	set.String("date", "", "doc")
+	set.String("from", "", "doc")
+	set.String("to", "", "doc")
+	set.Int("days", 1, "doc")
+	set.String("timezone", "", "doc")
+	set.Var(&cli.StringSlice{}, "calendar", "doc")
+	set.Var(&cli.StringSlice{}, "exclude-calendar", "doc")
+	set.Int("concurrency", 4, "doc")
+	set.String("output", "text", "doc")
+	set.String("format", "", "doc")
+	set.String("template-file", "", "doc")
+	set.Bool("show-end", false, "doc")
+}
+
+// assertGolden compares actual with testdata/name, rewriting the file instead when -update is given
+func assertGolden(t *testing.T, name string, actual string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.Nil(t, ioutil.WriteFile(path, []byte(actual), 0644))
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+// pkceChecker remembers the code challenge from an authorization request so the token request can be checked against it
+type pkceChecker struct {
+	mutex     sync.Mutex
+	challenge string
+}
+
+func (checker *pkceChecker) recordChallenge(t *testing.T, r *http.Request) {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	assert.Equal(t, "S256", r.FormValue("code_challenge_method"))
+	assert.Len(t, r.FormValue("code_challenge"), 43)
+	checker.challenge = r.FormValue("code_challenge")
+}
+
+func (checker *pkceChecker) verified(r *http.Request) bool {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	return checker.challenge != "" && base64.RawURLEncoding.EncodeToString(sum[:]) == checker.challenge
+}
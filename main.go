@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/guywithnose/calChecker/command"
 	"github.com/guywithnose/runner"
@@ -23,17 +24,256 @@ func main() {
 		os.Exit(2)
 	}
 
-	app.Action = command.CmdCheck(runner.Real{})
-	app.Flags = []cli.Flag{
+	outputFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "output",
+			Usage: "The output format: text, json or jsonl",
+			Value: "text",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "A Go template used to print each event, e.g. '{{.Start.Format \"15:04\"}} {{.Summary}}'",
+		},
 		cli.StringFlag{
-			Name:   "credentialFile",
-			Usage:  "The Gmail OAuth credential file",
-			EnvVar: "CALCHECKER_OAUTH_CREDENTIAL_FILE",
+			Name:  "template-file",
+			Usage: "A file containing a Go template used to print each event",
+		},
+		cli.BoolFlag{
+			Name:  "show-end",
+			Usage: "Show when each event ends and how long it lasts",
+		},
+	}
+
+	dateFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "date",
+			Usage: "The day to check (YYYY-MM-DD, today, tomorrow, yesterday or a weekday like monday)",
+		},
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "The first day of a range to check",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "The last day (inclusive) of a range to check",
+		},
+		cli.IntFlag{
+			Name:  "days",
+			Usage: "The number of days to check",
+			Value: command.DefaultDays,
 		},
+	}
+
+	calendarFlags := []cli.Flag{
 		cli.StringFlag{
-			Name:   "tokenFile",
-			Usage:  "The token file",
-			EnvVar: "CALCHECKER_TOKEN_FILE",
+			Name:  "timezone",
+			Usage: "The timezone used for day boundaries and times (defaults to the primary calendar's timezone)",
+		},
+		cli.StringSliceFlag{
+			Name:  "calendar",
+			Usage: "A calendar ID or summary regex to check (defaults to the calendars selected in Google Calendar)",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude-calendar",
+			Usage: "A calendar ID or summary regex to skip",
+		},
+		cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "The maximum number of calendars to fetch at once",
+			Value: command.DefaultConcurrency,
+		},
+	}
+
+	agendaFlags := append(append([]cli.Flag{}, dateFlags...), calendarFlags...)
+	checkFlags := append(append([]cli.Flag{}, outputFlags...), agendaFlags...)
+
+	// Checking the agenda is also the default when no command is given
+	app.Action = command.CmdCheck(runner.Real{})
+	app.Before = command.ApplyConfig
+	app.Flags = append(
+		[]cli.Flag{
+			cli.StringFlag{
+				Name:   "credentialFile",
+				Usage:  "The Google OAuth credential file or service account key",
+				EnvVar: "CALCHECKER_OAUTH_CREDENTIAL_FILE",
+			},
+			cli.StringFlag{
+				Name:   "tokenFile",
+				Usage:  "The token file",
+				Value:  command.DefaultTokenFile(),
+				EnvVar: "CALCHECKER_TOKEN_FILE",
+			},
+			cli.StringFlag{
+				Name:   "config",
+				Usage:  fmt.Sprintf("The config file with default values for the flags (default: %s)", command.ConfigFile()),
+				EnvVar: "CALCHECKER_CONFIG",
+			},
+			cli.StringFlag{
+				Name:   "profile",
+				Usage:  "Use the token of a named Google account, or all to merge every account that has logged in",
+				EnvVar: "CALCHECKER_PROFILE",
+			},
+			cli.StringFlag{
+				Name:   "impersonate",
+				Usage:  "The user a service account acts as through domain-wide delegation",
+				EnvVar: "CALCHECKER_IMPERSONATE",
+			},
+			cli.BoolFlag{
+				Name:  "adc",
+				Usage: "Use Google Application Default Credentials instead of a credential file",
+			},
+			cli.StringFlag{
+				Name:   "browser-command",
+				Usage:  "The command that opens the authorization URL, {url} is replaced by the URL",
+				Value:  command.DefaultBrowserCommand,
+				EnvVar: "CALCHECKER_BROWSER_COMMAND",
+			},
+			cli.StringFlag{
+				Name:  "redirect-host",
+				Usage: "The host the browser is redirected to after authorization",
+				Value: command.DefaultRedirectHost,
+			},
+			cli.IntFlag{
+				Name:  "redirect-port",
+				Usage: "The port the browser is redirected to after authorization (0 picks a free port)",
+			},
+			cli.BoolFlag{
+				Name:  "no-browser",
+				Usage: "Authorize by pasting the code from a browser on any computer instead of waiting for a redirect",
+			},
+			cli.BoolFlag{
+				Name:  "non-interactive",
+				Usage: "Fail with exit code 3 instead of asking for authorization when there is no token",
+			},
+			cli.DurationFlag{
+				Name:  "auth-timeout",
+				Usage: "How long to wait for authorization in the browser (0 waits forever)",
+				Value: 5 * time.Minute,
+			},
+			cli.BoolFlag{
+				Name:   "encrypt-token",
+				Usage:  "Encrypt the token file with a passphrase from $CALCHECKER_TOKEN_PASSPHRASE or a prompt",
+				EnvVar: "CALCHECKER_ENCRYPT_TOKEN",
+			},
+		},
+		checkFlags...,
+	)
+	app.Commands = []cli.Command{
+		{
+			Name:   "check",
+			Usage:  "Show the events for a range of days (the default command)",
+			Action: command.CmdCheck(runner.Real{}),
+			Before: command.ApplyConfig,
+			Flags:  checkFlags,
+		},
+		{
+			Name:   "export",
+			Usage:  "Export the events for a range of days",
+			Action: command.CmdExport(runner.Real{}),
+			Before: command.ApplyConfig,
+			Flags: append(
+				[]cli.Flag{
+					cli.StringFlag{
+						Name:  "format",
+						Usage: "The export format (only ics is supported)",
+						Value: "ics",
+					},
+					cli.StringFlag{
+						Name:  "file",
+						Usage: "The file to write to instead of stdout",
+					},
+					cli.BoolFlag{
+						Name:  "recurring",
+						Usage: "Export recurring events once with their recurrence rules instead of once per occurrence",
+					},
+				},
+				agendaFlags...,
+			),
+		},
+		{
+			Name:   "next",
+			Usage:  "Show the next upcoming events",
+			Action: command.CmdNext(runner.Real{}),
+			Before: command.ApplyConfig,
+			Flags: append(
+				append(
+					[]cli.Flag{
+						cli.IntFlag{
+							Name:  "count",
+							Usage: fmt.Sprintf("The number of events to show (defaults to %d, or every event with --within)", command.DefaultNextCount),
+							Value: command.DefaultNextCount,
+						},
+						cli.DurationFlag{
+							Name:  "within",
+							Usage: "Only show events that start within this long from now, e.g. 48h",
+						},
+					},
+					outputFlags...,
+				),
+				calendarFlags...,
+			),
+		},
+		{
+			Name:   "status",
+			Usage:  "Show the current and next events on one line for shell prompts and status bars",
+			Action: command.CmdStatus(runner.Real{}),
+			Before: command.ApplyConfig,
+			Flags: append(
+				[]cli.Flag{
+					cli.IntFlag{
+						Name:  "max-length",
+						Usage: "Shorten the event titles to keep the line at most this long (0 for no limit)",
+						Value: 60,
+					},
+					cli.StringFlag{
+						Name:  "output",
+						Usage: "The output format: text, waybar for a waybar custom module or i3bar for the status_command of i3bar",
+						Value: "text",
+					},
+					cli.DurationFlag{
+						Name:  "interval",
+						Usage: "How often --output i3bar updates the status",
+						Value: 30 * time.Second,
+					},
+					cli.BoolFlag{
+						Name:  "interactive",
+						Usage: "Ask for authorization when there is no token instead of failing with exit code 3",
+					},
+					cli.DurationFlag{
+						Name:  "cache-ttl",
+						Usage: "Reuse the events fetched by an earlier run for this long (0 disables the cache)",
+						Value: time.Minute,
+					},
+				},
+				calendarFlags...,
+			),
+		},
+		{
+			Name:  "auth",
+			Usage: "Manage access to Google Calendar",
+			Subcommands: []cli.Command{
+				{
+					Name:   "login",
+					Usage:  "Authorize calChecker and save the token",
+					Action: command.CmdAuthLogin(runner.Real{}),
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "device",
+							Usage: "Authorize by entering a code on another device instead of opening a browser here",
+						},
+					},
+				},
+				{
+					Name:   "status",
+					Usage:  "Show the saved token's expiry, refresh token and scopes",
+					Action: command.CmdAuthStatus(runner.Real{}),
+				},
+				{
+					Name:   "logout",
+					Usage:  "Revoke the saved token and delete the token file",
+					Action: command.CmdAuthLogout(runner.Real{}),
+				},
+			},
 		},
 	}
 	app.ErrWriter = os.Stderr